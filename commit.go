@@ -0,0 +1,84 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// temporaryDir is the directory, within a job's working location, that task
+// attempts write their output to before it is committed.
+const temporaryDir = "_temporary"
+
+// successMarker is written to a job's working location once every task of the job has committed
+const successMarker = "_SUCCESS"
+
+// attemptPath returns the directory that an attempt of a task writes its output to
+func (j *Job) attemptPath(phase Phase, binID uint, attempt int) string {
+	return j.fileSystem.Join(j.outputPath, temporaryDir, fmt.Sprintf("%s-%d-%d", phase, binID, attempt))
+}
+
+// commitTask promotes the output of a successful task attempt to the job's working location.
+// Files that are already committed (i.e. by an earlier attempt) are replaced.
+func (j *Job) commitTask(phase Phase, binID uint, attempt int) error {
+	attemptDir := j.attemptPath(phase, binID, attempt)
+	files, err := j.fileSystem.ListFiles(attemptDir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		name := strings.TrimLeft(strings.TrimPrefix(file.Name, attemptDir), "/\\")
+		if err := j.fileSystem.Rename(file.Name, j.fileSystem.Join(j.outputPath, name)); err != nil {
+			return fmt.Errorf("Unable to commit %s: %s", file.Name, err)
+		}
+	}
+
+	// Remove the (now empty) attempt directory. The attempt's output is already committed,
+	// so failing to remove it doesn't fail the task.
+	if err := j.fileSystem.DeleteAll(attemptDir); err != nil {
+		log.Error(err)
+	}
+	j.counters.commit(phase, binID, attempt)
+	return nil
+}
+
+// abortTask removes any output written by a task attempt, and discards its counters
+func (j *Job) abortTask(phase Phase, binID uint, attempt int) {
+	j.counters.discard(phase, binID, attempt)
+	if err := j.fileSystem.DeleteAll(j.attemptPath(phase, binID, attempt)); err != nil {
+		log.Error(err)
+	}
+}
+
+// removeReducerInputs deletes the intermediate map output read by a reducer
+func (j *Job) removeReducerInputs(binID uint) {
+	files, err := j.fileSystem.ListFiles(j.fileSystem.Join(j.outputPath, fmt.Sprintf("map-bin%d-*", binID)))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, file := range files {
+		if err := j.fileSystem.Delete(file.Name); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// commitJob marks the job's output as complete by writing a _SUCCESS marker,
+// once the output of every task has been committed.
+func (j *Job) commitJob(ctx context.Context) error {
+	// Remove the output of any attempts that weren't committed or aborted (e.g. attempts
+	// of an earlier driver run that was interrupted), so it isn't mistaken for existing output
+	if err := j.fileSystem.DeleteAll(j.fileSystem.Join(j.outputPath, temporaryDir)); err != nil {
+		return fmt.Errorf("Unable to remove %s: %s", temporaryDir, err)
+	}
+
+	marker, err := j.fileSystem.OpenWriter(ctx, j.fileSystem.Join(j.outputPath, successMarker))
+	if err != nil {
+		return err
+	}
+	return marker.Close()
+}
@@ -0,0 +1,105 @@
+package corral
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+func testCommitJob(t *testing.T) (*Job, string) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.fileSystem = corfs.InitFilesystem(corfs.Local)
+	job.outputPath = tmpdir
+	return job, tmpdir
+}
+
+func TestAttemptPath(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	assert.Equal(t, filepath.Join(tmpdir, "_temporary", "map-3-1"), job.attemptPath(MapPhase, 3, 1))
+	assert.Equal(t, filepath.Join(tmpdir, "_temporary", "reduce-0-2"), job.attemptPath(ReducePhase, 0, 2))
+}
+
+func TestCommitTask(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	attemptDir := job.attemptPath(ReducePhase, 0, 1)
+	assert.Nil(t, os.MkdirAll(attemptDir, 0700))
+	ioutil.WriteFile(filepath.Join(attemptDir, "output-part-0"), []byte("new"), 0600)
+	ioutil.WriteFile(filepath.Join(tmpdir, "output-part-0"), []byte("old"), 0600)
+
+	err := job.commitTask(ReducePhase, 0, 1)
+	assert.Nil(t, err)
+
+	// Committed output replaces the output of earlier attempts
+	contents, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Equal(t, "new", string(contents))
+
+	_, err = os.Stat(attemptDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestAbortTask(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	attemptDir := job.attemptPath(MapPhase, 2, 0)
+	assert.Nil(t, os.MkdirAll(attemptDir, 0700))
+	ioutil.WriteFile(filepath.Join(attemptDir, "map-bin0-2.out"), []byte("foo"), 0600)
+
+	job.abortTask(MapPhase, 2, 0)
+
+	_, err := os.Stat(attemptDir)
+	assert.True(t, os.IsNotExist(err))
+	files, err := filepath.Glob(filepath.Join(tmpdir, "map-bin*"))
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+}
+
+func TestCommitJob(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(tmpdir, "_temporary"), 0700))
+
+	err := job.commitJob(context.Background())
+	assert.Nil(t, err)
+
+	info, err := os.Stat(filepath.Join(tmpdir, "_SUCCESS"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), info.Size())
+
+	_, err = os.Stat(filepath.Join(tmpdir, "_temporary"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCommitJobRemovesAttempts(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	// Output of an attempt that was neither committed nor aborted
+	attemptDir := job.attemptPath(ReducePhase, 1, 0)
+	assert.Nil(t, os.MkdirAll(attemptDir, 0700))
+	ioutil.WriteFile(filepath.Join(attemptDir, "output-part-1"), []byte("foo"), 0600)
+
+	err := job.commitJob(context.Background())
+	assert.Nil(t, err)
+
+	_, err = os.Stat(filepath.Join(tmpdir, "_temporary"))
+	assert.True(t, os.IsNotExist(err))
+	leftover, err := job.fileSystem.ListFiles(job.fileSystem.Join(tmpdir, temporaryDir, "*", "*"))
+	assert.Nil(t, err)
+	assert.Empty(t, leftover)
+}
@@ -0,0 +1,111 @@
+package corral
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/golang/snappy"
+)
+
+// Compression identifies a codec used to compress intermediate data files.
+type Compression string
+
+// Supported compression codecs
+const (
+	// NoCompression writes intermediate data uncompressed.
+	NoCompression Compression = "none"
+	// GzipCompression compresses intermediate data with gzip. It has a good compression ratio, but is relatively slow.
+	GzipCompression Compression = "gzip"
+	// SnappyCompression compresses intermediate data with the (framed) snappy format. It is very fast, with a moderate compression ratio.
+	SnappyCompression Compression = "snappy"
+)
+
+// nopWriteCloser adds a no-op Close method to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// newCompressor wraps w so that data written to it is compressed with the given codec.
+// The returned writer must be closed to flush all compressed data; closing it does not close w.
+func newCompressor(compression Compression, w io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case NoCompression, "":
+		return nopWriteCloser{w}, nil
+	case GzipCompression:
+		return gzip.NewWriter(w), nil
+	case SnappyCompression:
+		return snappy.NewBufferedWriter(w), nil
+	}
+	return nil, fmt.Errorf("Unknown compression codec: '%s'", compression)
+}
+
+// newDecompressor wraps r so that data read from it is decompressed with the given codec.
+func newDecompressor(compression Compression, r io.Reader) (io.ReadCloser, error) {
+	switch compression {
+	case NoCompression, "":
+		return ioutil.NopCloser(r), nil
+	case GzipCompression:
+		return gzip.NewReader(r)
+	case SnappyCompression:
+		return ioutil.NopCloser(snappy.NewReader(r)), nil
+	}
+	return nil, fmt.Errorf("Unknown compression codec: '%s'", compression)
+}
+
+// Magic numbers of supported compressed input formats
+var (
+	gzipMagic  = []byte{0x1f, 0x8b, 0x08}
+	bzip2Magic = []byte("BZh")
+)
+
+// isCompressedInput returns true if filename has the extension of a supported compressed input format.
+// Compressed inputs cannot be split, as they must be decompressed from the beginning.
+func isCompressedInput(filename string) bool {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".gz", ".gzip", ".bz2", ".bzip2":
+		return true
+	}
+	return false
+}
+
+// hasCompressedInputMagic returns true if header begins with the magic number of a supported compressed input format
+func hasCompressedInputMagic(header []byte) bool {
+	if bytes.HasPrefix(header, gzipMagic) {
+		return true
+	}
+	// bzip2 headers are "BZh" followed by the block size ('1'-'9')
+	return bytes.HasPrefix(header, bzip2Magic) && len(header) > len(bzip2Magic) &&
+		header[len(bzip2Magic)] >= '1' && header[len(bzip2Magic)] <= '9'
+}
+
+// decompressInput detects whether the input read by r is compressed (by its magic number),
+// and if so, returns a reader of its decompressed contents. Uncompressed inputs are returned as-is.
+// The returned bool reports whether the input is compressed.
+func decompressInput(r io.Reader) (io.Reader, bool, error) {
+	buffered := bufio.NewReader(r)
+	header, err := buffered.Peek(len(bzip2Magic) + 1)
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	if !hasCompressedInputMagic(header) {
+		return buffered, false, nil
+	}
+
+	if bytes.HasPrefix(header, gzipMagic) {
+		gzipReader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, false, err
+		}
+		return gzipReader, true, nil
+	}
+	return bzip2.NewReader(buffered), true, nil
+}
@@ -0,0 +1,139 @@
+package corral
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error { return nil }
+
+func TestIntermediateCompressionRoundTrip(t *testing.T) {
+	records := []keyValue{
+		{"key", "value"},
+		{"key", strings.Repeat("compressible ", 100)},
+	}
+
+	for _, compression := range []Compression{NoCompression, GzipCompression, SnappyCompression} {
+		file := new(bufferCloser)
+		writer, err := newIntermediateWriter(file, BinaryFormat, compression)
+		assert.Nil(t, err)
+		for _, kv := range records {
+			assert.Nil(t, writer.write(kv))
+		}
+		assert.Nil(t, writer.close())
+		assert.Equal(t, int64(file.Len()), writer.bytesWritten())
+
+		reader, err := newIntermediateReader(file, BinaryFormat, compression)
+		assert.Nil(t, err, string(compression))
+		for _, kv := range records {
+			read, err := reader.next()
+			assert.Nil(t, err)
+			assert.Equal(t, kv, read)
+		}
+		_, err = reader.next()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestIntermediateCompressionReducesSize(t *testing.T) {
+	sizes := make(map[Compression]int64)
+	for _, compression := range []Compression{NoCompression, GzipCompression, SnappyCompression} {
+		writer, err := newIntermediateWriter(new(bufferCloser), BinaryFormat, compression)
+		assert.Nil(t, err)
+		for i := 0; i < 100; i++ {
+			assert.Nil(t, writer.write(keyValue{"key", "value"}))
+		}
+		assert.Nil(t, writer.close())
+		sizes[compression] = writer.bytesWritten()
+	}
+
+	assert.True(t, sizes[GzipCompression] < sizes[NoCompression])
+	assert.True(t, sizes[SnappyCompression] < sizes[NoCompression])
+}
+
+func TestIntermediateCompressionMismatch(t *testing.T) {
+	file := new(bufferCloser)
+	writer, err := newIntermediateWriter(file, BinaryFormat, NoCompression)
+	assert.Nil(t, err)
+	assert.Nil(t, writer.write(keyValue{"key", "value"}))
+	assert.Nil(t, writer.close())
+
+	_, err = newIntermediateReader(file, BinaryFormat, GzipCompression)
+	assert.NotNil(t, err)
+}
+
+func TestUnknownCompression(t *testing.T) {
+	_, err := newIntermediateWriter(new(bufferCloser), BinaryFormat, Compression("lz4"))
+	assert.NotNil(t, err)
+
+	_, err = newIntermediateReader(new(bytes.Buffer), BinaryFormat, Compression("lz4"))
+	assert.NotNil(t, err)
+}
+
+// bzip2-compressed "the test input\nthe test\n"
+var testBzip2Input = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x64, 0x26, 0x98, 0x7e, 0x00, 0x00,
+	0x0b, 0x51, 0x80, 0x00, 0x10, 0x40, 0x00, 0x02, 0x61, 0x4e, 0x00, 0x20, 0x00, 0x21, 0x2a, 0x69,
+	0x9a, 0x1a, 0x10, 0x03, 0x0d, 0xf4, 0x94, 0x7c, 0x29, 0x5a, 0x1b, 0x11, 0xa3, 0xe1, 0x77, 0x24,
+	0x53, 0x85, 0x09, 0x06, 0x42, 0x69, 0x87, 0xe0,
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	_, err := writer.Write([]byte(data))
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestIsCompressedInput(t *testing.T) {
+	assert.True(t, isCompressedInput("logs/input.gz"))
+	assert.True(t, isCompressedInput("s3://bucket/input.txt.GZ"))
+	assert.True(t, isCompressedInput("input.bz2"))
+	assert.False(t, isCompressedInput("input.txt"))
+	assert.False(t, isCompressedInput("gz"))
+}
+
+func TestDecompressInput(t *testing.T) {
+	var decompressTests = []struct {
+		input              []byte
+		expectedCompressed bool
+	}{
+		{[]byte("the test input\nthe test\n"), false},
+		{gzipBytes(t, "the test input\nthe test\n"), true},
+		{testBzip2Input, true},
+	}
+
+	for _, test := range decompressTests {
+		reader, compressed, err := decompressInput(bytes.NewReader(test.input))
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedCompressed, compressed)
+
+		data, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, "the test input\nthe test\n", string(data))
+	}
+}
+
+func TestDecompressInputShort(t *testing.T) {
+	for _, input := range []string{"", "B", "BZh", "BZhx"} {
+		reader, compressed, err := decompressInput(strings.NewReader(input))
+		assert.Nil(t, err)
+		assert.False(t, compressed)
+
+		data, err := ioutil.ReadAll(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, input, string(data))
+	}
+}
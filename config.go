@@ -19,17 +19,33 @@ func loadConfig() {
 
 func setupDefaults() {
 	defaultSettings := map[string]interface{}{
-		"lambdaFunctionName": "corral_function",
-		"lambdaMemory":       1500,
-		"lambdaTimeout":      180,
-		"lambdaManageRole":   true,
-		"cleanup":            true,
-		"verbose":            false,
-		"splitSize":          100 * 1024 * 1024, // Default input split size is 100Mb
-		"mapBinSize":         512 * 1024 * 1024, // Default map bin size is 512Mb
-		"reduceBinSize":      512 * 1024 * 1024, // Default reduce bin size is 512Mb
-		"maxConcurrency":     500,               // Maximum number of concurrent executors
-		"workingLocation":    ".",
+		"lambdaFunctionName":      "corral_function",
+		"lambdaMemory":            1500,
+		"lambdaTimeout":           180,
+		"lambdaManageRole":        true,
+		"cleanup":                 true,
+		"verbose":                 false,
+		"splitSize":               100 * 1024 * 1024, // Default input split size is 100Mb
+		"mapBinSize":              512 * 1024 * 1024, // Default map bin size is 512Mb
+		"reduceBinSize":           512 * 1024 * 1024, // Default reduce bin size is 512Mb
+		"numReducers":             0,                 // Number of reducers; 0 sizes reducers by reduceBinSize
+		"reducerSizing":           "input",           // How reducers are sized ("input" or "sample")
+		"combineBufferSize":       64 * 1024 * 1024,  // Default combiner buffer size is 64Mb
+		"sortBufferSize":          128 * 1024 * 1024, // Default reducer sort buffer size is 128Mb
+		"spillLocation":           "",                // Spill sorted runs to a local temporary directory by default
+		"intermediateFormat":      "binary",          // Encoding of intermediate shuffle data ("binary" or "json")
+		"intermediateCompression": "none",            // Compression codec of intermediate shuffle data ("none", "gzip" or "snappy")
+		"maxConcurrency":          500,               // Maximum number of concurrent executors
+		"workingLocation":         ".",
+		"timeout":                 0,      // Maximum duration of a driver run; 0 disables the timeout
+		"overwrite":               "none", // How to treat existing output ("none", "overwrite" or "delete")
+		"resume":                  false,  // Resume from the progress of an earlier run
+		"maxTaskAttempts":         3,      // Maximum number of times a failed task is run
+		"retryBackoff":            "1s",   // Delay before the first retry of a failed task
+		"maxRetryBackoff":         "30s",  // Maximum delay between retries of a failed task
+		"speculativeExecution":    false,  // Launch duplicate attempts of straggling tasks
+		"speculationQuantile":     0.75,   // Fraction of a phase's tasks that must finish before speculating
+		"speculationMultiplier":   2.0,    // Tasks running this many times longer than the median are stragglers
 	}
 	for key, value := range defaultSettings {
 		viper.SetDefault(key, value)
@@ -0,0 +1,161 @@
+package corral
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// MalformedRecordsCounter counts the malformed input records that were skipped by mappers
+const MalformedRecordsCounter = "malformed_records"
+
+// Counter is a named count that is aggregated across all of a job's tasks (e.g. the number
+// of invalid rows in the job's input). Counters are threadsafe.
+// Only the counts of task attempts that are committed contribute to a job's counters, so
+// counts aren't duplicated by retried or speculative attempts.
+type Counter struct {
+	value int64
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds n to the counter
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.value, n)
+}
+
+// Value returns the current count
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+// counterSet holds the named counters of a single task attempt
+type counterSet struct {
+	mut      sync.Mutex
+	counters map[string]*Counter
+}
+
+// Counter returns the counter with the given name, creating it if necessary
+func (s *counterSet) Counter(name string) *Counter {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[string]*Counter)
+	}
+	counter, ok := s.counters[name]
+	if !ok {
+		counter = &Counter{}
+		s.counters[name] = counter
+	}
+	return counter
+}
+
+// values returns the value of each counter in the set
+func (s *counterSet) values() map[string]int64 {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	values := make(map[string]int64, len(s.counters))
+	for name, counter := range s.counters {
+		values[name] = counter.Value()
+	}
+	return values
+}
+
+// jobCounters aggregates the counters of a job's task attempts. The counters of each attempt
+// are recorded when it finishes, and added to the job's totals if the attempt is committed.
+type jobCounters struct {
+	mut      sync.Mutex
+	attempts map[string]map[string]int64
+	totals   map[string]int64
+}
+
+func attemptKey(phase Phase, binID uint, attempt int) string {
+	return fmt.Sprintf("%s-%d-%d", phase, binID, attempt)
+}
+
+// record records the counters of a task attempt that succeeded
+func (c *jobCounters) record(phase Phase, binID uint, attempt int, values map[string]int64) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.attempts == nil {
+		c.attempts = make(map[string]map[string]int64)
+	}
+	c.attempts[attemptKey(phase, binID, attempt)] = values
+}
+
+// attempt returns the recorded counters of a task attempt
+func (c *jobCounters) attempt(phase Phase, binID uint, attempt int) map[string]int64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.attempts[attemptKey(phase, binID, attempt)]
+}
+
+// commit adds the recorded counters of a task attempt to the job's totals
+func (c *jobCounters) commit(phase Phase, binID uint, attempt int) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	key := attemptKey(phase, binID, attempt)
+	if c.totals == nil {
+		c.totals = make(map[string]int64)
+	}
+	for name, value := range c.attempts[key] {
+		c.totals[name] += value
+	}
+	delete(c.attempts, key)
+}
+
+// discard discards the recorded counters of a task attempt
+func (c *jobCounters) discard(phase Phase, binID uint, attempt int) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	delete(c.attempts, attemptKey(phase, binID, attempt))
+}
+
+// reset discards all recorded counters and totals
+func (c *jobCounters) reset() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.attempts = nil
+	c.totals = nil
+}
+
+// values returns the job's counter totals
+func (c *jobCounters) values() map[string]int64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	values := make(map[string]int64, len(c.totals))
+	for name, value := range c.totals {
+		values[name] = value
+	}
+	return values
+}
+
+// Counters returns the values of the job's counters, aggregated across its committed tasks
+func (j *Job) Counters() map[string]int64 {
+	return j.counters.values()
+}
+
+// printCounters prints the counters of each of the driver's jobs
+func (d *Driver) printCounters() {
+	for idx, job := range d.jobs {
+		values := job.Counters()
+		if len(values) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Printf("Counters of %s:\n", d.jobName(idx))
+		for _, name := range names {
+			fmt.Printf("\t%s: %d\n", name, values[name])
+		}
+	}
+}
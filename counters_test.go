@@ -0,0 +1,80 @@
+package corral
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	var counters counterSet
+	counters.Counter("foo").Inc()
+	counters.Counter("foo").Add(2)
+	counters.Counter("bar")
+
+	assert.Equal(t, int64(3), counters.Counter("foo").Value())
+	assert.Equal(t, map[string]int64{"foo": 3, "bar": 0}, counters.values())
+}
+
+func TestJobCounters(t *testing.T) {
+	var counters jobCounters
+	counters.record(MapPhase, 0, 0, map[string]int64{"foo": 1})
+	counters.record(MapPhase, 0, 1, map[string]int64{"foo": 1})
+	counters.record(MapPhase, 1, 0, map[string]int64{"foo": 2, "bar": 1})
+
+	assert.Equal(t, map[string]int64{"foo": 1}, counters.attempt(MapPhase, 0, 1))
+	assert.Empty(t, counters.values())
+
+	// Only committed attempts contribute to the job's counters
+	counters.commit(MapPhase, 0, 1)
+	counters.discard(MapPhase, 0, 0)
+	counters.commit(MapPhase, 1, 0)
+	assert.Equal(t, map[string]int64{"foo": 3, "bar": 1}, counters.values())
+
+	counters.reset()
+	assert.Empty(t, counters.values())
+}
+
+// testCountingJob is a word count job that counts the words and keys it processes
+type testCountingJob struct {
+	testWCJob
+}
+
+func (testCountingJob) Map(key, value string, emitter Emitter) {
+	for _, word := range strings.Fields(value) {
+		emitter.Counter("words").Inc()
+		emitter.Emit(word, "")
+	}
+}
+
+func (j testCountingJob) Reduce(key string, values ValueIterator, emitter Emitter) {
+	emitter.Counter("keys").Inc()
+	j.testWCJob.Reduce(key, values, emitter)
+}
+
+func TestRunWithCounters(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("the quick brown fox jumps over the lazy dog"), 0600)
+
+	job := NewJob(testCountingJob{}, testCountingJob{})
+	driver := NewDriver(job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithSplitSize(10),
+		WithMapBinSize(10),
+		WithNumReducers(2),
+	)
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]int64{"words": 9, "keys": 8}, job.Counters())
+}
@@ -0,0 +1,241 @@
+package corral
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+// CSVInputFormat reads CSV (RFC 4180) records. Quoted fields may contain delimiters,
+// quotes, and newlines. Records are passed to the mapper as a value (the raw CSV text
+// of the record) with an empty key, or, if the job's Mapper implements FieldsMapper,
+// as parsed fields.
+type CSVInputFormat struct {
+	// Comma is the field delimiter. Defaults to ','.
+	Comma rune
+	// Comment, if set, is the character that begins comment lines. Comment lines are skipped.
+	Comment rune
+	// Header indicates that the first record of each file is a header row.
+	// Header rows are not passed to Map, but are passed to each call to MapFields.
+	Header bool
+	// SingleLineRecords indicates that no record spans multiple lines (i.e. quoted fields
+	// don't contain newlines). Files that may contain multi-line records are scanned once
+	// by the driver to align split boundaries to record boundaries; setting SingleLineRecords
+	// skips this scan.
+	SingleLineRecords bool
+}
+
+// Splittable returns true for all files
+func (CSVInputFormat) Splittable(filename string) bool {
+	return true
+}
+
+// NewRecordReader returns a RecordReader over the CSV records of split.
+// The returned RecordReader also implements FieldsRecordReader.
+func (c CSVInputFormat) NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error) {
+	return c.newHeaderRecordReader(split, r, nil)
+}
+
+func (c CSVInputFormat) hasHeader() bool {
+	return c.Header
+}
+
+// newHeaderRecordReader returns a RecordReader over the CSV records of split. If the split
+// doesn't begin at the start of its file, the file's header row is read from header.
+func (c CSVInputFormat) newHeaderRecordReader(split InputSplit, r io.Reader, header io.Reader) (RecordReader, error) {
+	reader := &csvRecordReader{
+		records: c.newRawRecordReader(split, r),
+		format:  c,
+	}
+	if !c.Header || split.StartOffset == 0 || header == nil {
+		return reader, nil
+	}
+
+	headerReader := &csvRecordReader{
+		records: c.newRawRecordReader(InputSplit{Filename: split.Filename, EndOffset: math.MaxInt64 - 1}, header),
+		format:  c,
+	}
+	if err := headerReader.readHeader(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	reader.header = headerReader.header
+	return reader, nil
+}
+
+// newRawRecordReader returns a reader of the unparsed records of split
+func (c CSVInputFormat) newRawRecordReader(split InputSplit, r io.Reader) *delimitedRecordReader {
+	splitFunc := scanCSVRecords(c.Comment)
+	if c.SingleLineRecords {
+		splitFunc = bufio.ScanLines
+	}
+	return newDelimitedRecordReader(split, r, splitFunc, 1, func(offset int64, record string) (string, string) {
+		return "", record
+	})
+}
+
+// alignSplits moves the boundaries of splits to the start of CSV records, so that
+// mappers don't begin reading in the middle of a multi-line record.
+// It reads the entire file, so is only used for files that span multiple splits.
+func (c CSVInputFormat) alignSplits(ctx context.Context, fs corfs.FileSystem, splits []InputSplit) ([]InputSplit, error) {
+	if c.SingleLineRecords || len(splits) <= 1 {
+		return splits, nil
+	}
+
+	file, err := fs.OpenReader(ctx, splits[0].Filename, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+
+	fileEnd := splits[len(splits)-1].EndOffset
+	aligned := make([]InputSplit, 0, len(splits))
+	aligned = append(aligned, splits[0])
+
+	var offset int64
+	recordStart, quoted, comment := true, false, false
+	for _, split := range splits[1:] {
+		// Advance to the first record start at or after the split's start
+		for offset < split.StartOffset || !recordStart {
+			b, err := reader.ReadByte()
+			if err == io.EOF {
+				aligned[len(aligned)-1].EndOffset = fileEnd
+				return aligned, nil
+			} else if err != nil {
+				return nil, err
+			}
+			offset++
+
+			if recordStart && c.Comment != 0 && rune(b) == c.Comment {
+				comment = true
+			}
+			recordStart = false
+			switch {
+			case b == '"' && !comment:
+				quoted = !quoted
+			case b == '\n' && !quoted:
+				recordStart, comment = true, false
+			}
+		}
+
+		if offset == aligned[len(aligned)-1].StartOffset {
+			// Multiple splits begin within the same record
+			continue
+		}
+		aligned[len(aligned)-1].EndOffset = offset - 1
+		aligned = append(aligned, InputSplit{
+			Filename:    split.Filename,
+			StartOffset: offset,
+			EndOffset:   fileEnd,
+		})
+	}
+	return aligned, nil
+}
+
+// scanCSVRecords returns a bufio.SplitFunc that splits data into (unparsed) CSV records.
+// Records are terminated by newlines that aren't within a quoted field.
+func scanCSVRecords(comment rune) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		isComment := comment != 0 && bytes.HasPrefix(data, []byte(string(comment)))
+		quoted := false
+		for i, b := range data {
+			switch {
+			case b == '"' && !isComment:
+				quoted = !quoted
+			case b == '\n' && !quoted:
+				return i + 1, data[:i], nil
+			}
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// csvRecordReader parses the CSV records of a split
+type csvRecordReader struct {
+	records    *delimitedRecordReader
+	format     CSVInputFormat
+	header     []string
+	headerRead bool
+}
+
+// readHeader reads the header row, if the split begins at the start of its file
+func (c *csvRecordReader) readHeader() error {
+	c.headerRead = true
+	if !c.format.Header || c.records.split.StartOffset != 0 {
+		return nil
+	}
+
+	_, header, err := c.next()
+	if err != nil {
+		return err
+	}
+	c.header = header
+	return nil
+}
+
+// next returns the unparsed text and fields of the next (non-empty, non-comment) record
+func (c *csvRecordReader) next() (string, []string, error) {
+	for {
+		_, record, err := c.records.Next()
+		if err != nil {
+			return "", nil, err
+		}
+
+		reader := csv.NewReader(strings.NewReader(record + "\n"))
+		if c.format.Comma != 0 {
+			reader.Comma = c.format.Comma
+		}
+		reader.Comment = c.format.Comment
+		reader.FieldsPerRecord = -1
+
+		fields, err := reader.Read()
+		if err == io.EOF {
+			// Blank lines and comments aren't records
+			continue
+		} else if err != nil {
+			return "", nil, err
+		}
+		return record, fields, nil
+	}
+}
+
+// Next returns the raw text of the next record, with an empty key
+func (c *csvRecordReader) Next() (string, string, error) {
+	if !c.headerRead {
+		if err := c.readHeader(); err != nil {
+			return "", "", err
+		}
+	}
+
+	record, _, err := c.next()
+	return "", record, err
+}
+
+// NextFields returns the header and parsed fields of the next record
+func (c *csvRecordReader) NextFields() ([]string, []string, error) {
+	if !c.headerRead {
+		if err := c.readHeader(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	_, fields, err := c.next()
+	return c.header, fields, err
+}
+
+func (c *csvRecordReader) recordOffset() int64 {
+	return c.records.recordOffset()
+}
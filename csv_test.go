@@ -0,0 +1,122 @@
+package corral
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+	"github.com/stretchr/testify/assert"
+)
+
+type csvRecord struct {
+	header []string
+	fields []string
+}
+
+// readCSVSplits reads every record of a CSV file, divided into (aligned) splits of at most splitSize bytes
+func readCSVSplits(t *testing.T, format CSVInputFormat, filename string, splitSize int64) []csvRecord {
+	job := &Job{
+		InputFormat: format,
+		fileSystem:  &corfs.LocalFileSystem{},
+	}
+	fInfo, err := job.fileSystem.Stat(filename)
+	assert.Nil(t, err)
+
+	splits, err := format.alignSplits(context.Background(), job.fileSystem, splitInputFile(fInfo, splitSize))
+	assert.Nil(t, err)
+
+	records := make([]csvRecord, 0)
+	for _, split := range splits {
+		reader, err := job.fileSystem.OpenReader(context.Background(), filename, split.StartOffset)
+		assert.Nil(t, err)
+
+		recordReader, err := job.newRecordReader(context.Background(), split, reader)
+		assert.Nil(t, err)
+		for {
+			header, fields, err := recordReader.(FieldsRecordReader).NextFields()
+			if err == io.EOF {
+				break
+			}
+			assert.Nil(t, err)
+			if err != nil {
+				break
+			}
+			records = append(records, csvRecord{header, fields})
+		}
+		reader.Close()
+	}
+	return records
+}
+
+func testCSVInputFormat(t *testing.T, format CSVInputFormat, data string, expected []csvRecord) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	filename := filepath.Join(tmpdir, "input.csv")
+	ioutil.WriteFile(filename, []byte(data), 0700)
+
+	for splitSize := int64(1); splitSize <= int64(len(data))+1; splitSize++ {
+		records := readCSVSplits(t, format, filename, splitSize)
+		assert.Equal(t, expected, records, fmt.Sprintf("split size: %d", splitSize))
+	}
+}
+
+func TestCSVInputFormat(t *testing.T) {
+	data := "a,\"b,c\",d\n\"multi\nline\",\"quoted \"\"quote\"\"\",\n\n1,2,3\r\nlast"
+	testCSVInputFormat(t, CSVInputFormat{}, data, []csvRecord{
+		{nil, []string{"a", "b,c", "d"}},
+		{nil, []string{"multi\nline", "quoted \"quote\"", ""}},
+		{nil, []string{"1", "2", "3"}},
+		{nil, []string{"last"}},
+	})
+}
+
+func TestCSVInputFormatHeader(t *testing.T) {
+	format := CSVInputFormat{
+		Comma:   ';',
+		Comment: '#',
+		Header:  true,
+	}
+	header := []string{"name", "value"}
+	data := "name;value\n# \"comment\n\"foo;bar\";1\nbaz;\"2\n3\"\n"
+	testCSVInputFormat(t, format, data, []csvRecord{
+		{header, []string{"foo;bar", "1"}},
+		{header, []string{"baz", "2\n3"}},
+	})
+}
+
+func TestCSVInputFormatSingleLineRecords(t *testing.T) {
+	format := CSVInputFormat{
+		Header:            true,
+		SingleLineRecords: true,
+	}
+	header := []string{"name", "value"}
+	data := "name,value\n\"foo,\"\"bar\",1\nbaz,2\n"
+	testCSVInputFormat(t, format, data, []csvRecord{
+		{header, []string{"foo,\"bar", "1"}},
+		{header, []string{"baz", "2"}},
+	})
+}
+
+func TestCSVInputFormatNext(t *testing.T) {
+	format := CSVInputFormat{Header: true, SingleLineRecords: true}
+	testInputFormat(t, format, "h1,h2\na,\"b,c\"\nd,e", []keyValue{
+		{"", "a,\"b,c\""},
+		{"", "d,e"},
+	})
+}
+
+func TestCSVInputFormatMalformed(t *testing.T) {
+	reader, err := CSVInputFormat{}.NewRecordReader(InputSplit{EndOffset: 9}, bytes.NewReader([]byte("a,b\"c\"\n")))
+	assert.Nil(t, err)
+
+	_, _, err = reader.Next()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,211 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	humanize "github.com/dustin/go-humanize"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+// errJobSkipped is recorded for jobs that aren't run because a job they depend on failed
+var errJobSkipped = errors.New("Job skipped")
+
+// jobName returns the name of the job at idx
+func (d *Driver) jobName(idx int) string {
+	if d.jobs[idx].Name != "" {
+		return d.jobs[idx].Name
+	}
+	return fmt.Sprintf("job%d", idx)
+}
+
+// jobWorkingLocation returns the location that the job at idx writes its output to.
+// Jobs that were given their own working location write their output to it directly.
+func (d *Driver) jobWorkingLocation(fs corfs.FileSystem, idx int) string {
+	location := d.jobs[idx].config.WorkingLocation
+	if len(d.jobs) > 1 && location == d.config.WorkingLocation {
+		return fs.Join(location, d.jobName(idx))
+	}
+	return location
+}
+
+// jobDependencies returns the indices of the jobs that each job reads the output of.
+// Jobs that don't declare Inputs or InputJobs depend on the previous job.
+// It returns an error if the jobs don't form a DAG.
+func (d *Driver) jobDependencies() ([][]int, error) {
+	indices := make(map[*Job]int, len(d.jobs))
+	names := make(map[string]bool, len(d.jobs))
+	for idx, job := range d.jobs {
+		if _, exists := indices[job]; exists {
+			return nil, fmt.Errorf("Job %s appears more than once in the driver", d.jobName(idx))
+		}
+		if names[d.jobName(idx)] {
+			return nil, fmt.Errorf("Multiple jobs are named %s", d.jobName(idx))
+		}
+		indices[job] = idx
+		names[d.jobName(idx)] = true
+	}
+
+	deps := make([][]int, len(d.jobs))
+	for idx, job := range d.jobs {
+		if len(job.Inputs) == 0 && len(job.InputJobs) == 0 {
+			if idx > 0 {
+				deps[idx] = []int{idx - 1}
+			}
+			continue
+		}
+		for _, inputJob := range job.InputJobs {
+			depIdx, ok := indices[inputJob]
+			if !ok {
+				return nil, fmt.Errorf("Job %s reads the output of a job that isn't part of the driver", d.jobName(idx))
+			}
+			deps[idx] = append(deps[idx], depIdx)
+		}
+	}
+
+	// Check for cycles with a depth-first search
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(d.jobs))
+	var visit func(idx int) error
+	visit = func(idx int) error {
+		switch state[idx] {
+		case visiting:
+			return fmt.Errorf("Job %s depends on its own output", d.jobName(idx))
+		case visited:
+			return nil
+		}
+		state[idx] = visiting
+		for _, dep := range deps[idx] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[idx] = visited
+		return nil
+	}
+	for idx := range d.jobs {
+		if err := visit(idx); err != nil {
+			return nil, err
+		}
+	}
+	return deps, nil
+}
+
+// jobInputs returns the inputs of the job at idx: its declared Inputs and the output of its
+// InputJobs, or else the output of the previous job (or the driver's inputs, for the first job),
+// followed by any additional inputs set by the job's options.
+// The jobs that it depends on must have been started.
+func (d *Driver) jobInputs(idx int, deps []int) []string {
+	job := d.jobs[idx]
+	if len(job.Inputs) == 0 && len(job.InputJobs) == 0 && idx == 0 {
+		return append(append([]string{}, d.config.Inputs...), job.config.Inputs...)
+	}
+
+	inputs := append([]string{}, job.Inputs...)
+	for _, dep := range deps {
+		depJob := d.jobs[dep]
+		inputs = append(inputs, depJob.fileSystem.Join(depJob.outputPath, "output-*"))
+	}
+	return append(inputs, job.config.Inputs...)
+}
+
+// runJobs runs the driver's jobs, each once all of the jobs it depends on have completed.
+// Jobs that don't depend on each other run concurrently. Once any job fails, no more jobs
+// are started. It returns the error of the first (by index) job that failed.
+func (d *Driver) runJobs(ctx context.Context, deps [][]int) error {
+	// failed is closed once any job fails
+	failed := make(chan struct{})
+	var failOnce sync.Once
+
+	done := make([]chan struct{}, len(d.jobs))
+	for idx := range done {
+		done[idx] = make(chan struct{})
+	}
+	errs := make([]error, len(d.jobs))
+
+	var wg sync.WaitGroup
+	for idx := range d.jobs {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			defer close(done[idx])
+
+			for _, dep := range deps[idx] {
+				<-done[dep]
+				if errs[dep] != nil {
+					errs[idx] = errJobSkipped
+					return
+				}
+			}
+			select {
+			case <-failed:
+				errs[idx] = errJobSkipped
+				return
+			default:
+			}
+
+			if errs[idx] = d.runStage(ctx, idx, d.jobInputs(idx, deps[idx])); errs[idx] != nil {
+				failOnce.Do(func() { close(failed) })
+			}
+		}(idx)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil && err != errJobSkipped {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStage runs the job at idx, resuming it if the driver is resuming
+func (d *Driver) runStage(ctx context.Context, idx int, inputs []string) error {
+	job := d.jobs[idx]
+	name := d.jobName(idx)
+	if len(inputs) == 0 {
+		return fmt.Errorf("%s: No inputs", name)
+	}
+
+	// Initialize job filesystem
+	job.fileSystem = corfs.InferFilesystem(inputs[0])
+	job.outputPath = d.jobWorkingLocation(job.fileSystem, idx)
+
+	manifest, err := d.resumeJob(ctx, job, idx, inputs)
+	if err != nil {
+		return err
+	}
+	if manifest.Complete {
+		log.Infof("Skipping completed %s (%d/%d)", name, idx+1, len(d.jobs))
+		return nil
+	}
+
+	if manifest.MapBins != nil {
+		log.Infof("Resuming %s (%d/%d)", name, idx+1, len(d.jobs))
+	} else {
+		log.Infof("Starting %s (%d/%d)", name, idx+1, len(d.jobs))
+	}
+	d.logSettings(idx)
+	if err := d.runJob(ctx, job, idx, inputs, manifest); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			d.removeIntermediateFiles(job, manifest)
+			return fmt.Errorf("%s: %w", name, ctxErr)
+		}
+		return err
+	}
+
+	log.Infof("Job %s - Total Bytes Read:\t%s", name, humanize.Bytes(uint64(job.bytesRead)))
+	log.Infof("Job %s - Total Bytes Written:\t%s", name, humanize.Bytes(uint64(job.bytesWritten)))
+	if malformed := job.Counters()[MalformedRecordsCounter]; malformed > 0 {
+		log.Warnf("Job %s - Skipped %d malformed input record(s)", name, malformed)
+	}
+	return nil
+}
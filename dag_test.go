@@ -0,0 +1,203 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+func TestJobDependencies(t *testing.T) {
+	job0 := NewJob(testWCJob{}, testWCJob{})
+	job1 := NewJob(testWCJob{}, testWCJob{})
+	job2 := NewJob(testWCJob{}, testWCJob{})
+	driver := NewMultiStageDriver([]*Job{job0, job1, job2})
+
+	// Jobs without inputs read the output of the previous job
+	deps, err := driver.jobDependencies()
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{nil, {0}, {1}}, deps)
+
+	job1.Inputs = []string{"foo"}
+	job2.InputJobs = []*Job{job0, job1}
+	deps, err = driver.jobDependencies()
+	assert.Nil(t, err)
+	assert.Equal(t, [][]int{nil, nil, {0, 1}}, deps)
+
+	job0.InputJobs = []*Job{job2}
+	_, err = driver.jobDependencies()
+	assert.EqualError(t, err, "Job job0 depends on its own output")
+
+	job0.InputJobs = []*Job{NewJob(testWCJob{}, testWCJob{})}
+	_, err = driver.jobDependencies()
+	assert.EqualError(t, err, "Job job0 reads the output of a job that isn't part of the driver")
+
+	job0.InputJobs = nil
+	job1.Name = "job2"
+	_, err = driver.jobDependencies()
+	assert.EqualError(t, err, "Multiple jobs are named job2")
+
+	driver.jobs = []*Job{job0, job0}
+	_, err = driver.jobDependencies()
+	assert.EqualError(t, err, "Job job1 appears more than once in the driver")
+}
+
+func TestJobInputs(t *testing.T) {
+	fs := corfs.InitFilesystem(corfs.Local)
+	job0 := NewJob(testWCJob{}, testWCJob{})
+	job1 := NewJob(testWCJob{}, testWCJob{})
+	job2 := NewJob(testWCJob{}, testWCJob{})
+	for _, job := range []*Job{job0, job1, job2} {
+		job.fileSystem = fs
+	}
+	job0.outputPath = "out/job0"
+	job1.outputPath = "out/preprocess"
+
+	driver := NewMultiStageDriver([]*Job{job0, job1, job2}, WithInputs("input"))
+	assert.Equal(t, []string{"input"}, driver.jobInputs(0, nil))
+	assert.Equal(t, []string{filepath.Join("out", "job0", "output-*")}, driver.jobInputs(1, []int{0}))
+
+	job2.Inputs = []string{"side_input"}
+	job2.InputJobs = []*Job{job0, job1}
+	assert.Equal(t, []string{
+		"side_input",
+		filepath.Join("out", "job0", "output-*"),
+		filepath.Join("out", "preprocess", "output-*"),
+	}, driver.jobInputs(2, []int{0, 1}))
+}
+
+// testPrefixJob prefixes the words of its input with prefix, and outputs each
+// distinct word as both a key and a value
+type testPrefixJob struct {
+	prefix string
+}
+
+func (p testPrefixJob) Map(key, value string, emitter Emitter) {
+	for _, word := range strings.Fields(value) {
+		emitter.Emit(p.prefix+word, "")
+	}
+}
+
+func (p testPrefixJob) Reduce(key string, values ValueIterator, emitter Emitter) {
+	emitter.Emit(key, key)
+}
+
+// barrierExecutor blocks the map tasks of the jobs in barrier until the map tasks of
+// all of them have started
+type barrierExecutor struct {
+	localExecutor
+	barrier sync.WaitGroup
+	jobs    map[int]bool
+	timeout bool
+}
+
+func (b *barrierExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	if b.jobs[jobNumber] {
+		b.barrier.Done()
+
+		waited := make(chan struct{})
+		go func() {
+			b.barrier.Wait()
+			close(waited)
+		}()
+		select {
+		case <-waited:
+		case <-time.After(5 * time.Second):
+			b.timeout = true
+		}
+	}
+	return b.localExecutor.RunMapper(ctx, job, jobNumber, binID, attempt, inputSplits)
+}
+
+func TestRunJobDAG(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	leftInput := filepath.Join(tmpdir, "left")
+	rightInput := filepath.Join(tmpdir, "right")
+	ioutil.WriteFile(leftInput, []byte("foo bar"), 0700)
+	ioutil.WriteFile(rightInput, []byte("bar baz"), 0700)
+
+	left := NewJob(testPrefixJob{"left_"}, testPrefixJob{"left_"})
+	left.Name = "left"
+	left.Inputs = []string{leftInput}
+
+	right := NewJob(testPrefixJob{"right_"}, testPrefixJob{"right_"})
+	right.Name = "right"
+	right.Inputs = []string{rightInput}
+
+	join := NewJob(testWCJob{}, testWCJob{})
+	join.Name = "join"
+	join.InputJobs = []*Job{left, right}
+
+	driver := NewMultiStageDriver([]*Job{join, left, right},
+		WithWorkingLocation(filepath.Join(tmpdir, "out")),
+	)
+
+	// The independent jobs must run concurrently to pass the barrier
+	executor := &barrierExecutor{jobs: map[int]bool{1: true, 2: true}}
+	executor.barrier.Add(2)
+	driver.executor = executor
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+	assert.False(t, executor.timeout)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "out", "join", "output-part-0"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []keyValue{
+		{"left_foo", "1"},
+		{"left_bar", "1"},
+		{"right_bar", "1"},
+		{"right_baz", "1"},
+	}, testOutputToKeyValues(string(output)))
+
+	for _, name := range []string{"left", "right", "join"} {
+		_, err = os.Stat(filepath.Join(tmpdir, "out", name, "_SUCCESS"))
+		assert.Nil(t, err, name)
+	}
+}
+
+func TestRunJobDAGFailure(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("foo bar"), 0700)
+
+	left := NewJob(testWCJob{}, testWCJob{})
+	left.Inputs = []string{inputPath}
+	right := NewJob(testWCJob{}, testWCJob{})
+	right.Name = "right"
+	right.Inputs = []string{inputPath}
+	join := NewJob(testWCJob{}, testWCJob{})
+	join.InputJobs = []*Job{left, right}
+
+	driver := NewMultiStageDriver([]*Job{left, right, join},
+		WithWorkingLocation(filepath.Join(tmpdir, "out")),
+		WithRetryPolicy(NoRetries),
+	)
+	driver.executor = &recordingExecutor{failTasks: map[string]bool{"job1-map-0": true}}
+
+	err = driver.Run(context.Background())
+	jobErr, ok := err.(*JobError)
+	assert.True(t, ok, fmt.Sprint(err))
+	assert.Equal(t, 1, jobErr.JobNumber)
+	assert.Equal(t, "right", jobErr.JobName)
+	assert.True(t, strings.HasPrefix(err.Error(), "right: 1 map task(s) failed"), err.Error())
+
+	// Jobs that depend on the failed job aren't run
+	_, err = os.Stat(filepath.Join(tmpdir, "out", "job2"))
+	assert.True(t, os.IsNotExist(err))
+}
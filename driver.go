@@ -2,15 +2,16 @@ package corral
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"runtime"
 	"runtime/pprof"
 	"sync"
+	"syscall"
 	"time"
 
-	"github.com/dustin/go-humanize"
-
 	"github.com/spf13/viper"
 
 	"golang.org/x/sync/semaphore"
@@ -19,7 +20,6 @@ import (
 	pb "gopkg.in/cheggaaa/pb.v1"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/bcongdon/corral/internal/pkg/corfs"
 	flag "github.com/spf13/pflag"
 )
 
@@ -28,17 +28,32 @@ type Driver struct {
 	jobs     []*Job
 	config   *config
 	executor executor
+	sem      *semaphore.Weighted
 }
 
 // config configures a Driver's execution of jobs
 type config struct {
-	Inputs          []string
-	SplitSize       int64
-	MapBinSize      int64
-	ReduceBinSize   int64
-	MaxConcurrency  int
-	WorkingLocation string
-	Cleanup         bool
+	Inputs                  []string
+	SplitSize               int64
+	MapBinSize              int64
+	ReduceBinSize           int64
+	NumReducers             int
+	ReducerSizing           ReducerSizing
+	MaxConcurrency          int
+	WorkingLocation         string
+	Cleanup                 bool
+	Timeout                 time.Duration
+	CombineBufferSize       int64
+	SortBufferSize          int64
+	SpillLocation           string
+	IntermediateFormat      IntermediateFormat
+	IntermediateCompression Compression
+	Overwrite               OverwriteMode
+	Resume                  bool
+	RetryPolicy             RetryPolicy
+	SpeculativeExecution    bool
+	Speculation             SpeculationPolicy
+	Params                  map[string]string
 }
 
 func newConfig() *config {
@@ -49,13 +64,34 @@ func newConfig() *config {
 	viper.BindPFlags(flag.CommandLine)
 
 	return &config{
-		Inputs:          []string{},
-		SplitSize:       viper.GetInt64("splitSize"),
-		MapBinSize:      viper.GetInt64("mapBinSize"),
-		ReduceBinSize:   viper.GetInt64("reduceBinSize"),
-		MaxConcurrency:  viper.GetInt("maxConcurrency"),
-		WorkingLocation: viper.GetString("workingLocation"),
-		Cleanup:         viper.GetBool("cleanup"),
+		Inputs:                  []string{},
+		SplitSize:               viper.GetInt64("splitSize"),
+		MapBinSize:              viper.GetInt64("mapBinSize"),
+		ReduceBinSize:           viper.GetInt64("reduceBinSize"),
+		NumReducers:             viper.GetInt("numReducers"),
+		ReducerSizing:           ReducerSizing(viper.GetString("reducerSizing")),
+		MaxConcurrency:          viper.GetInt("maxConcurrency"),
+		WorkingLocation:         viper.GetString("workingLocation"),
+		Cleanup:                 viper.GetBool("cleanup"),
+		Timeout:                 viper.GetDuration("timeout"),
+		CombineBufferSize:       viper.GetInt64("combineBufferSize"),
+		SortBufferSize:          viper.GetInt64("sortBufferSize"),
+		SpillLocation:           viper.GetString("spillLocation"),
+		IntermediateFormat:      IntermediateFormat(viper.GetString("intermediateFormat")),
+		IntermediateCompression: Compression(viper.GetString("intermediateCompression")),
+		Overwrite:               OverwriteMode(viper.GetString("overwrite")),
+		Resume:                  viper.GetBool("resume"),
+		RetryPolicy: RetryPolicy{
+			MaxAttempts:    viper.GetInt("maxTaskAttempts"),
+			InitialBackoff: viper.GetDuration("retryBackoff"),
+			MaxBackoff:     viper.GetDuration("maxRetryBackoff"),
+		},
+		SpeculativeExecution: viper.GetBool("speculativeExecution"),
+		Speculation: SpeculationPolicy{
+			Quantile:   viper.GetFloat64("speculationQuantile"),
+			Multiplier: viper.GetFloat64("speculationMultiplier"),
+		},
+		Params: viper.GetStringMapString("params"),
 	}
 }
 
@@ -113,6 +149,59 @@ func WithReduceBinSize(s int64) Option {
 	}
 }
 
+// WithNumReducers sets the number of reducers (and thus output parts) of each job. By default,
+// the number of reducers is determined from ReduceBinSize.
+func WithNumReducers(n int) Option {
+	return func(c *config) {
+		c.NumReducers = n
+	}
+}
+
+// WithReducerSizing sets how the number of reducers is determined, when it isn't set with WithNumReducers
+func WithReducerSizing(sizing ReducerSizing) Option {
+	return func(c *config) {
+		c.ReducerSizing = sizing
+	}
+}
+
+// WithCombineBufferSize sets the maximum size (in bytes) of map output that is buffered
+// in each mapper before being combined by the Job's combiner
+func WithCombineBufferSize(s int64) Option {
+	return func(c *config) {
+		c.CombineBufferSize = s
+	}
+}
+
+// WithSortBufferSize sets the maximum size (in bytes) of intermediate data that each
+// reducer sorts in memory before spilling sorted runs to the spill location
+func WithSortBufferSize(s int64) Option {
+	return func(c *config) {
+		c.SortBufferSize = s
+	}
+}
+
+// WithSpillLocation sets the location (local or S3) that reducers spill sorted runs to.
+// By default, runs are spilled to a local temporary directory.
+func WithSpillLocation(location string) Option {
+	return func(c *config) {
+		c.SpillLocation = location
+	}
+}
+
+// WithIntermediateFormat sets the encoding of intermediate (shuffle) data
+func WithIntermediateFormat(format IntermediateFormat) Option {
+	return func(c *config) {
+		c.IntermediateFormat = format
+	}
+}
+
+// WithIntermediateCompression sets the compression codec used for intermediate (shuffle) data
+func WithIntermediateCompression(compression Compression) Option {
+	return func(c *config) {
+		c.IntermediateCompression = compression
+	}
+}
+
 // WithWorkingLocation sets the location and filesystem backend of the Driver
 func WithWorkingLocation(location string) Option {
 	return func(c *config) {
@@ -120,6 +209,59 @@ func WithWorkingLocation(location string) Option {
 	}
 }
 
+// WithMaxConcurrency sets the maximum number of tasks that the Driver runs at once
+func WithMaxConcurrency(n int) Option {
+	return func(c *config) {
+		c.MaxConcurrency = n
+	}
+}
+
+// WithCleanup sets whether intermediate files are deleted once they're no longer needed
+func WithCleanup(cleanup bool) Option {
+	return func(c *config) {
+		c.Cleanup = cleanup
+	}
+}
+
+// WithOverwrite allows the Driver to overwrite, or first delete, the output of earlier runs at
+// its working location. By default, the Driver fails rather than overwriting existing output.
+func WithOverwrite(mode OverwriteMode) Option {
+	return func(c *config) {
+		c.Overwrite = mode
+	}
+}
+
+// WithResume resumes the Driver's jobs from the progress of an earlier, failed run. Completed
+// jobs are skipped, and only the incomplete map and reduce tasks of other jobs are run.
+func WithResume() Option {
+	return func(c *config) {
+		c.Resume = true
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed map and reduce tasks
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithSpeculativeExecution enables speculative execution of straggling tasks, using the given policy
+func WithSpeculativeExecution(policy SpeculationPolicy) Option {
+	return func(c *config) {
+		c.SpeculativeExecution = true
+		c.Speculation = policy
+	}
+}
+
+// WithTimeout sets the maximum duration of a Driver run. Tasks that are still
+// running when the timeout elapses are cancelled. A zero timeout means no limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.Timeout = timeout
+	}
+}
+
 // WithInputs specifies job inputs (i.e. input files/directories)
 func WithInputs(inputs ...string) Option {
 	return func(c *config) {
@@ -127,57 +269,275 @@ func WithInputs(inputs ...string) Option {
 	}
 }
 
-func (d *Driver) runMapPhase(job *Job, jobNumber int, inputs []string) {
-	inputSplits := job.inputSplits(inputs, d.config.SplitSize)
-	if len(inputSplits) == 0 {
-		log.Warnf("No input splits")
-		return
+// WithParam sets a parameter that Mappers and Reducers can read from their TaskContext
+func WithParam(key, value string) Option {
+	return func(c *config) {
+		// Copy the parameters, as a job's options are applied to a copy of the driver's config
+		params := make(map[string]string, len(c.Params)+1)
+		for k, v := range c.Params {
+			params[k] = v
+		}
+		params[key] = value
+		c.Params = params
+	}
+}
+
+// taskFunc runs an attempt of the task for binID
+type taskFunc func(ctx context.Context, binID uint, attempt int) error
+
+// runTask runs attempts of a task until one succeeds and is committed, or the task fails
+// with an error that the configured RetryPolicy doesn't retry. Failed attempts are aborted,
+// and no retries are started once ctx is done. It returns the number of attempts that were run.
+func (d *Driver) runTask(ctx context.Context, job *Job, task *phaseTask, run taskFunc) (int, error) {
+	policy := job.config.RetryPolicy
+	for failures := 1; ; failures++ {
+		attempt, attemptCtx, ok := task.startAttempt()
+		if !ok {
+			// A speculative attempt has already been committed
+			return task.numAttempts(), nil
+		}
+		committed, err := task.finishAttempt(job, attempt, run(attemptCtx, task.binID, attempt))
+		if committed {
+			return task.numAttempts(), nil
+		}
+
+		if failures >= policy.maxAttempts() || !policy.retryable(err) || ctx.Err() != nil {
+			return task.numAttempts(), err
+		}
+		backoff := policy.backoff(failures)
+		log.Warnf("%s task %d failed (attempt %d of %d), retrying in %s: %s", task.phase, task.binID, failures, policy.maxAttempts(), backoff, err)
+		if sleepContext(ctx, backoff) != nil {
+			return task.numAttempts(), err
+		}
+	}
+}
+
+// runPhase runs the tasks of a phase for each of binIDs, with at most MaxConcurrency running at once.
+// If speculative execution is enabled, duplicate attempts of straggling tasks are launched.
+// It returns the IDs of the tasks that completed, and a *JobError if any task failed.
+func (d *Driver) runPhase(ctx context.Context, job *Job, jobNumber int, phase Phase, binIDs []uint, bar *pb.ProgressBar, run taskFunc) ([]uint, error) {
+	// Stop scheduling new tasks as soon as any task fails
+	schedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tasks := make([]*phaseTask, len(binIDs))
+	for i, binID := range binIDs {
+		tasks[i] = newPhaseTask(schedCtx, phase, binID)
 	}
-	log.Debugf("Number of job input splits: %d", len(inputSplits))
 
-	inputBins := packInputSplits(inputSplits, d.config.MapBinSize)
-	log.Debugf("Number of job input bins: %d", len(inputBins))
-	bar := pb.New(len(inputBins)).Prefix("Map").Start()
+	var wg, speculativeWg sync.WaitGroup
+	var failures taskFailures
+	slots := job.slots
 
-	var wg sync.WaitGroup
-	sem := semaphore.NewWeighted(int64(d.config.MaxConcurrency))
-	for binID, bin := range inputBins {
-		sem.Acquire(context.Background(), 1)
+	speculationCtx, stopSpeculation := context.WithCancel(schedCtx)
+	speculationDone := make(chan struct{})
+	go func() {
+		defer close(speculationDone)
+		if job.config.SpeculativeExecution {
+			d.speculate(speculationCtx, job, tasks, slots, &speculativeWg, run)
+		}
+	}()
+
+	for _, task := range tasks {
+		if err := slots.acquire(schedCtx); err != nil {
+			break
+		}
 		wg.Add(1)
-		go func(bID uint, b []inputSplit) {
+		go func(task *phaseTask) {
 			defer wg.Done()
-			defer sem.Release(1)
+			defer slots.release()
 			defer bar.Increment()
-			err := d.executor.RunMapper(job, jobNumber, bID, b)
+			attempts, err := d.runTask(schedCtx, job, task, run)
+			if err == nil && phase == ReducePhase && job.config.Cleanup {
+				job.removeReducerInputs(task.binID)
+			}
+			if err != nil && ctx.Err() == nil && errors.Is(err, context.Canceled) {
+				// The task was cancelled because another task of the phase failed
+				return
+			}
 			if err != nil {
-				log.Errorf("Error when running mapper %d: %s", bID, err)
+				log.Errorf("Error when running %s task %d: %s", phase, task.binID, err)
+				failures.add(&TaskError{JobNumber: jobNumber, Phase: phase, BinID: task.binID, Attempts: attempts, Err: err})
+				cancel()
 			}
-		}(uint(binID), bin)
+		}(task)
 	}
 	wg.Wait()
+	stopSpeculation()
+	<-speculationDone
+	speculativeWg.Wait()
 	bar.Finish()
+
+	completed := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		if task.isCommitted() {
+			completed = append(completed, task.binID)
+		}
+	}
+	return completed, failures.err(jobNumber, job.Name, phase)
 }
 
-func (d *Driver) runReducePhase(job *Job, jobNumber int) {
-	var wg sync.WaitGroup
-	bar := pb.New(int(job.intermediateBins)).Prefix("Reduce").Start()
-	for binID := uint(0); binID < job.intermediateBins; binID++ {
-		wg.Add(1)
-		go func(bID uint) {
-			defer wg.Done()
-			defer bar.Increment()
-			err := d.executor.RunReducer(job, jobNumber, bID)
+// runMapPhase runs the job's pending map tasks. Tasks are determined from the job's inputs
+// when the job is started, and recorded in its manifest.
+func (d *Driver) runMapPhase(ctx context.Context, job *Job, jobNumber int, inputs []string, manifest *jobManifest) error {
+	if manifest.MapBins == nil {
+		inputSplits := job.inputSplits(ctx, inputs, job.config.SplitSize)
+		log.Debugf("Number of job input splits: %d", len(inputSplits))
+
+		manifest.MapBins = packInputSplits(inputSplits, job.config.MapBinSize)
+		manifest.IntermediateBins = job.numReducers(ctx, inputSplits)
+		if job.TotalOrder {
+			splitPoints, err := job.sampleSplitPoints(ctx, inputSplits, manifest.IntermediateBins)
 			if err != nil {
-				log.Errorf("Error when running reducer %d: %s", bID, err)
+				return fmt.Errorf("%s: Unable to sample split points: %s", d.jobName(jobNumber), err)
 			}
-		}(binID)
+			manifest.SplitPoints = splitPoints
+		} else if job.SaltHotKeys > 1 && manifest.IntermediateBins > 1 {
+			hotKeys, err := job.sampleHotKeys(ctx, inputSplits, manifest.IntermediateBins)
+			if err != nil {
+				return fmt.Errorf("%s: Unable to sample hot keys: %s", d.jobName(jobNumber), err)
+			}
+			manifest.HotKeys = hotKeys
+		}
+		if err := job.writeManifest(manifest); err != nil {
+			return err
+		}
 	}
-	wg.Wait()
-	bar.Finish()
+	job.intermediateBins = manifest.IntermediateBins
+	job.splitPoints = manifest.SplitPoints
+	job.hotKeys = manifest.HotKeys
+	log.Infof("Job %s - Reducers: %d", d.jobName(jobNumber), job.intermediateBins)
+	if len(job.hotKeys) > 0 {
+		log.Infof("Job %s - Salting %d hot key(s) across %d reducers: %v", d.jobName(jobNumber), len(job.hotKeys), job.SaltHotKeys, job.hotKeys)
+	}
+	if len(manifest.MapBins) == 0 {
+		log.Warnf("No input splits")
+		return nil
+	}
+	log.Debugf("Number of job input bins: %d", len(manifest.MapBins))
+
+	pending := manifest.pendingBins(MapPhase)
+	bar := pb.New(len(pending)).Prefix("Map").Start()
+	completed, err := d.runPhase(ctx, job, jobNumber, MapPhase, pending, bar, func(ctx context.Context, binID uint, attempt int) error {
+		return d.executor.RunMapper(ctx, job, jobNumber, binID, attempt, manifest.MapBins[binID])
+	})
+	if err == nil {
+		job.shuffle.report(job.intermediateBins).log(d.jobName(jobNumber))
+	}
+
+	manifest.complete(MapPhase, completed)
+	if manifestErr := job.writeManifest(manifest); err == nil {
+		err = manifestErr
+	}
+	return err
+}
+
+// runReducePhase runs the job's pending reduce tasks. If the job has salted hot keys, the
+// partial results of the hot keys are then merged by an extra reduce task.
+func (d *Driver) runReducePhase(ctx context.Context, job *Job, jobNumber int, manifest *jobManifest) error {
+	runReducer := func(ctx context.Context, binID uint, attempt int) error {
+		return d.executor.RunReducer(ctx, job, jobNumber, binID, attempt)
+	}
+
+	pending := manifest.pendingBins(ReducePhase)
+	bar := pb.New(len(pending)).Prefix("Reduce").Start()
+	completed, err := d.runPhase(ctx, job, jobNumber, ReducePhase, pending, bar, runReducer)
+	manifest.complete(ReducePhase, completed)
+
+	if err == nil && len(manifest.HotKeys) > 0 && !manifest.hotKeysMerged() {
+		bar := pb.New(1).Prefix("Merge").Start()
+		completed, err = d.runPhase(ctx, job, jobNumber, ReducePhase, []uint{manifest.IntermediateBins}, bar, runReducer)
+		manifest.complete(ReducePhase, completed)
+	}
+
+	if manifestErr := job.writeManifest(manifest); err == nil {
+		err = manifestErr
+	}
+	return err
+}
+
+// runJob runs the pending map and reduce tasks of a single job, recording its progress in manifest
+func (d *Driver) runJob(ctx context.Context, job *Job, jobNumber int, inputs []string, manifest *jobManifest) error {
+	if err := d.runMapPhase(ctx, job, jobNumber, inputs, manifest); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := d.runReducePhase(ctx, job, jobNumber, manifest); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := job.commitJob(ctx); err != nil {
+		return err
+	}
+
+	manifest.Complete = true
+	return job.writeManifest(manifest)
+}
+
+// removeIntermediateFiles deletes any intermediate map output and uncommitted
+// task output left behind by an aborted job. As the output of completed map tasks
+// is deleted, they're recorded as pending in the job's manifest.
+func (d *Driver) removeIntermediateFiles(job *Job, manifest *jobManifest) {
+	if !job.config.Cleanup {
+		return
+	}
+
+	for _, pattern := range []string{"map-bin*", job.fileSystem.Join(temporaryDir, "*", "*")} {
+		files, err := job.fileSystem.ListFiles(job.fileSystem.Join(job.outputPath, pattern))
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		for _, file := range files {
+			if err := job.fileSystem.Delete(file.Name); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+
+	manifest.CompletedMapBins = nil
+	if len(manifest.HotKeys) > 0 {
+		// The partial results of completed reducers were deleted, so all reducers must be re-run
+		manifest.CompletedReduceBins = nil
+	}
+	if err := job.writeManifest(manifest); err != nil {
+		log.Error(err)
+	}
+}
+
+// resumeJob returns the manifest of the job to run, which records the progress of an earlier
+// run of the job if the driver is resuming. It returns an error if the job's inputs or
+// settings have changed since the earlier run.
+func (d *Driver) resumeJob(ctx context.Context, job *Job, jobNumber int, inputs []string) (*jobManifest, error) {
+	manifest := newJobManifest(inputs, job)
+	if !d.config.Resume {
+		return manifest, nil
+	}
+
+	previous, err := job.readManifest(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: Unable to read manifest: %s", d.jobName(jobNumber), err)
+	}
+	if previous == nil {
+		return manifest, nil
+	}
+	if !manifest.resumableFrom(previous) {
+		return nil, fmt.Errorf("%s: Unable to resume, as its inputs or settings have changed since the previous run", d.jobName(jobNumber))
+	}
+	return previous, nil
 }
 
-// run starts the Driver
-func (d *Driver) run() {
+// Run executes the Driver's jobs. Each job is run once the jobs whose output it reads have
+// completed, and independent jobs run concurrently. If any task of a job fails, the remaining
+// tasks of that phase are not scheduled, no further jobs are started, and a *JobError
+// describing every failed task is returned.
+// If ctx is cancelled or the configured timeout elapses, in-flight tasks are stopped,
+// intermediate files of the current job are removed, and the context's error is returned.
+func (d *Driver) Run(ctx context.Context) error {
 	if runningInLambda() {
 		lambdaDriver = d
 		lambda.Start(handleRequest)
@@ -186,34 +546,29 @@ func (d *Driver) run() {
 		lBackend.Deploy()
 	}
 
-	if len(d.config.Inputs) == 0 {
-		log.Error("No inputs!")
-		return
+	deps, err := d.jobDependencies()
+	if err != nil {
+		return err
+	}
+	d.sem = semaphore.NewWeighted(int64(d.config.MaxConcurrency))
+	d.configureJobs()
+	if len(d.config.Inputs) == 0 && len(d.jobs[0].Inputs) == 0 && len(d.jobs[0].InputJobs) == 0 && len(d.jobs[0].config.Inputs) == 0 {
+		return errors.New("No inputs")
 	}
 
-	inputs := d.config.Inputs
-	for idx, job := range d.jobs {
-		// Initialize job filesystem
-		job.fileSystem = corfs.InferFilesystem(inputs[0])
-
-		jobWorkingLoc := d.config.WorkingLocation
-		log.Infof("Starting job%d (%d/%d)", idx, idx+1, len(d.jobs))
-
-		if len(d.jobs) > 1 {
-			jobWorkingLoc = job.fileSystem.Join(jobWorkingLoc, fmt.Sprintf("job%d", idx))
+	if !d.config.Resume {
+		if err := d.checkExistingOutput(ctx); err != nil {
+			return err
 		}
-		job.outputPath = jobWorkingLoc
-
-		*job.config = *d.config
-		d.runMapPhase(job, idx, inputs)
-		d.runReducePhase(job, idx)
-
-		// Set inputs of next job to be outputs of current job
-		inputs = []string{job.fileSystem.Join(jobWorkingLoc, "output-*")}
+	}
 
-		log.Infof("Job %d - Total Bytes Read:\t%s", idx, humanize.Bytes(uint64(job.bytesRead)))
-		log.Infof("Job %d - Total Bytes Written:\t%s", idx, humanize.Bytes(uint64(job.bytesWritten)))
+	if d.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.config.Timeout)
+		defer cancel()
 	}
+
+	return d.runJobs(ctx, deps)
 }
 
 var lambdaFlag = flag.Bool("lambda", false, "Use lambda backend")
@@ -221,8 +576,16 @@ var outputDir = flag.StringP("out", "o", "", "Output `directory` (can be local o
 var memprofile = flag.String("memprofile", "", "Write memory profile to `file`")
 var verbose = flag.BoolP("verbose", "v", false, "Output verbose logs")
 var undeploy = flag.Bool("undeploy", false, "Undeploy the Lambda function and IAM permissions without running the driver")
+var resume = flag.Bool("resume", false, "Resume a failed run, skipping completed jobs and tasks")
+var overwrite = flag.String("overwrite", string(NoOverwrite), "Overwrite existing output. Set to `delete` to delete existing output before running")
+
+func init() {
+	// Allow "--overwrite" to be passed without a value
+	flag.Lookup("overwrite").NoOptDefVal = string(OverwriteOutput)
+}
 
 // Main starts the Driver, running the submitted jobs.
+// If any job fails, Main logs the error and exits the program with a non-zero status.
 func (d *Driver) Main() {
 	if viper.GetBool("verbose") {
 		log.SetLevel(log.DebugLevel)
@@ -243,10 +606,15 @@ func (d *Driver) Main() {
 		d.config.WorkingLocation = *outputDir
 	}
 
+	// Stop the running jobs when the program is interrupted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	start := time.Now()
-	d.run()
+	err := d.Run(ctx)
 	end := time.Now()
 	fmt.Printf("Job Execution Time: %s\n", end.Sub(start))
+	d.printCounters()
 
 	if *memprofile != "" {
 		f, err := os.Create(*memprofile)
@@ -259,4 +627,8 @@ func (d *Driver) Main() {
 		}
 		f.Close()
 	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,12 +1,16 @@
 package corral
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -113,6 +117,12 @@ func TestLocalMapReduce(t *testing.T) {
 	for _, kv := range correct {
 		assert.Contains(t, keyVals, kv)
 	}
+
+	// Output is committed, and no uncommitted task output remains
+	_, err = os.Stat(filepath.Join(tmpdir, "_SUCCESS"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(tmpdir, "_temporary"))
+	assert.True(t, os.IsNotExist(err))
 }
 
 func TestLocalMultiJob(t *testing.T) {
@@ -153,11 +163,15 @@ func TestLocalMultiJob(t *testing.T) {
 }
 
 func TestLocalNoCrashOnNoResolvedInputFiles(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
 	job := NewJob(testWCJob{}, testWCJob{})
 	driver := NewDriver(
 		job,
 		WithInputs("does_not_exist"),
-		WithWorkingLocation("some_file"),
+		WithWorkingLocation(tmpdir),
 	)
 
 	driver.Main()
@@ -217,3 +231,486 @@ func TestLocalStructFieldMapReduce(t *testing.T) {
 		assert.Contains(t, keyVals, kv)
 	}
 }
+
+type failingMapJob struct{}
+
+func (failingMapJob) Map(key, value string, emitter Emitter) {
+	emitter.Emit(key, value)
+}
+
+func (failingMapJob) Reduce(key string, values ValueIterator, emitter Emitter) {
+	for value := range values.Iter() {
+		emitter.Emit(key, value)
+	}
+}
+
+type failingExecutor struct {
+	localExecutor
+	failMapBins map[uint]bool
+}
+
+func (f failingExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	if f.failMapBins[binID] {
+		return fmt.Errorf("mapper %d exploded", binID)
+	}
+	return f.localExecutor.RunMapper(ctx, job, jobNumber, binID, attempt, inputSplits)
+}
+
+func TestRunReturnsJobError(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	for i := 0; i < 4; i++ {
+		inputPath := filepath.Join(tmpdir, fmt.Sprintf("test_input%d", i))
+		ioutil.WriteFile(inputPath, []byte("foo bar baz"), 0700)
+	}
+
+	job1 := NewJob(failingMapJob{}, failingMapJob{})
+	job2 := NewJob(failingMapJob{}, failingMapJob{})
+	driver := NewMultiStageDriver([]*Job{job1, job2},
+		WithInputs(tmpdir),
+		WithWorkingLocation(tmpdir),
+		WithSplitSize(11),
+		WithMapBinSize(11),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2}),
+	)
+	driver.config.MaxConcurrency = 1
+	driver.executor = failingExecutor{failMapBins: map[uint]bool{0: true}}
+
+	err = driver.Run(context.Background())
+	assert.NotNil(t, err)
+
+	jobErr, ok := err.(*JobError)
+	assert.True(t, ok)
+	assert.Equal(t, 0, jobErr.JobNumber)
+	assert.Equal(t, MapPhase, jobErr.Phase)
+	assert.Len(t, jobErr.Tasks, 1)
+	assert.Equal(t, uint(0), jobErr.Tasks[0].BinID)
+	assert.Equal(t, 2, jobErr.Tasks[0].Attempts)
+	assert.EqualError(t, jobErr.Tasks[0].Err, "mapper 0 exploded")
+
+	// Later stages must not be started
+	assert.Equal(t, "", job2.outputPath)
+	_, err = os.Stat(filepath.Join(tmpdir, "job1"))
+	assert.True(t, os.IsNotExist(err))
+
+	// The failed job's output is not committed
+	_, err = os.Stat(filepath.Join(tmpdir, "_SUCCESS"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunNoInputs(t *testing.T) {
+	driver := NewDriver(NewJob(testWCJob{}, testWCJob{}))
+	err := driver.Run(context.Background())
+	assert.NotNil(t, err)
+}
+
+type blockingExecutor struct {
+	localExecutor
+}
+
+func (b blockingExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	if err := b.localExecutor.RunMapper(ctx, job, jobNumber, binID, attempt, inputSplits); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunTimeout(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input\nthe input test\nfoo bar baz"), 0700)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	driver := NewDriver(job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithTimeout(50*time.Millisecond),
+	)
+	driver.executor = blockingExecutor{}
+
+	err = driver.Run(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// Intermediate files of the aborted job are removed
+	files, err := filepath.Glob(filepath.Join(tmpdir, "map-bin*"))
+	assert.Nil(t, err)
+	assert.Empty(t, files)
+	_, err = os.Stat(filepath.Join(tmpdir, "output-part-0"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunCancelled(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input"), 0700)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	driver := NewDriver(job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = driver.Run(ctx)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestLocalMapReduceWithCombiner(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input\nthe input test\nfoo bar baz"), 0700)
+
+	job := NewJob(testWCJob{}, testSumCombiner{})
+	job.Combine = testSumCombiner{}
+	driver := NewDriver(
+		job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+
+	keyVals := testOutputToKeyValues(string(output))
+	assert.Len(t, keyVals, 6)
+	assert.Contains(t, keyVals, keyValue{"the", "2"})
+	assert.Contains(t, keyVals, keyValue{"baz", "1"})
+}
+
+func TestLocalMapReduceSortedOutput(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input\nthe input test\nfoo bar baz"), 0700)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	driver := NewDriver(
+		job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []keyValue{
+		{"bar", "1"},
+		{"baz", "1"},
+		{"foo", "1"},
+		{"input", "2"},
+		{"test", "2"},
+		{"the", "2"},
+	}, testOutputToKeyValues(string(output)))
+}
+
+func TestLocalMapReduceWithSpills(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input\nthe input test\nfoo bar baz"), 0700)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	driver := NewDriver(
+		job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithSortBufferSize(8),
+		WithSpillLocation(filepath.Join(tmpdir, "spill")),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []keyValue{
+		{"bar", "1"},
+		{"baz", "1"},
+		{"foo", "1"},
+		{"input", "2"},
+		{"test", "2"},
+		{"the", "2"},
+	}, testOutputToKeyValues(string(output)))
+
+	// Spilled runs are cleaned up
+	runs, err := filepath.Glob(filepath.Join(tmpdir, "spill", "*", "run-*"))
+	assert.Nil(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestLocalMapReduceJSONIntermediateFormat(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input"), 0700)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	driver := NewDriver(
+		job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithIntermediateFormat(JSONFormat),
+	)
+	driver.config.Cleanup = false
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	intermediate, err := ioutil.ReadFile(filepath.Join(tmpdir, "map-bin0-0.out"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(intermediate), `{"key":"the","value":"1"}`)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Len(t, testOutputToKeyValues(string(output)), 3)
+}
+
+func TestLocalMapReduceCompressedIntermediate(t *testing.T) {
+	for _, compression := range []Compression{GzipCompression, SnappyCompression} {
+		tmpdir, err := ioutil.TempDir("", "test")
+		assert.Nil(t, err)
+		defer os.RemoveAll(tmpdir)
+
+		inputPath := filepath.Join(tmpdir, "test_input")
+		ioutil.WriteFile(inputPath, []byte("the test input the test"), 0700)
+
+		job := NewJob(testWCJob{}, testWCJob{})
+		driver := NewDriver(
+			job,
+			WithInputs(inputPath),
+			WithWorkingLocation(tmpdir),
+			WithIntermediateCompression(compression),
+			WithSortBufferSize(1),
+		)
+		driver.config.Cleanup = false
+
+		err = driver.Run(context.Background())
+		assert.Nil(t, err)
+
+		intermediate, err := ioutil.ReadFile(filepath.Join(tmpdir, "map-bin0-0.out"))
+		assert.Nil(t, err)
+		assert.False(t, bytes.HasPrefix(intermediate, binaryMagic))
+
+		output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+		assert.Nil(t, err)
+		assert.ElementsMatch(t, []keyValue{
+			{"input", "1"},
+			{"test", "2"},
+			{"the", "2"},
+		}, testOutputToKeyValues(string(output)))
+	}
+}
+
+func TestLocalMapReduceCompressedInputs(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputDir := filepath.Join(tmpdir, "inputs")
+	os.Mkdir(inputDir, 0700)
+	ioutil.WriteFile(filepath.Join(inputDir, "input.gz"), gzipBytes(t, "the test input\nthe test\n"), 0700)
+	ioutil.WriteFile(filepath.Join(inputDir, "input.bz2"), testBzip2Input, 0700)
+	// Compressed inputs without a compressed file extension are detected by their magic numbers
+	ioutil.WriteFile(filepath.Join(inputDir, "input"), gzipBytes(t, "the test input\nthe test\n"), 0700)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	driver := NewDriver(
+		job,
+		WithInputs(inputDir),
+		WithWorkingLocation(tmpdir),
+		WithSplitSize(4),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []keyValue{
+		{"input", "3"},
+		{"test", "6"},
+		{"the", "6"},
+	}, testOutputToKeyValues(string(output)))
+}
+
+func TestLocalMapReduceInputFormat(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("foo=the test|;bar=the input|;baz=other"), 0700)
+
+	job := NewJob(&testFilterJob{prefix: "ba"}, &testFilterJob{})
+	job.InputFormat = DelimitedInputFormat{
+		RecordDelimiter:   "|;",
+		KeyValueSeparator: "=",
+	}
+	driver := NewDriver(
+		job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithSplitSize(5),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Equal(t, []keyValue{
+		{"bar", "the input"},
+		{"baz", "other"},
+	}, testOutputToKeyValues(string(output)))
+}
+
+type testCSVJob struct{}
+
+func (testCSVJob) Map(key, value string, emitter Emitter) {
+	emitter.Emit("unparsed", value)
+}
+
+func (testCSVJob) MapFields(header, fields []string, emitter Emitter) {
+	for i, field := range fields {
+		emitter.Emit(header[i], field)
+	}
+}
+
+func (testCSVJob) Reduce(key string, values ValueIterator, emitter Emitter) {
+	for value := range values.Iter() {
+		emitter.Emit(key, value)
+	}
+}
+
+func TestLocalMapReduceCSVInputFormat(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input.csv")
+	ioutil.WriteFile(inputPath, []byte("name,city\n\"Doe, Jane\",Boston\n\"Roe,\nRichard\",Denver\n"), 0700)
+
+	job := NewJob(testCSVJob{}, testCSVJob{})
+	job.InputFormat = CSVInputFormat{Header: true}
+	driver := NewDriver(
+		job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithSplitSize(15),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Equal(t, "city\tBoston\ncity\tDenver\nname\tDoe, Jane\nname\tRoe,\nRichard\n", string(output))
+}
+
+type testJSONLinesJob struct{}
+
+func (testJSONLinesJob) Map(key, value string, emitter Emitter) {
+	emitter.Emit("unparsed", value)
+}
+
+func (testJSONLinesJob) MapRecord(key string, record interface{}, emitter Emitter) {
+	emitter.Emit(key, record.(*testJSONRecord).Name)
+}
+
+func (testJSONLinesJob) Reduce(key string, values ValueIterator, emitter Emitter) {
+	for value := range values.Iter() {
+		emitter.Emit(key, value)
+	}
+}
+
+func TestLocalMapReduceJSONLinesInputFormat(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input.json")
+	ioutil.WriteFile(inputPath, []byte("{\"name\":\"foo\",\"count\":1}\n{malformed\n{\"name\":\"bar\",\"count\":2}\n"), 0700)
+
+	job := NewJob(testJSONLinesJob{}, testJSONLinesJob{})
+	job.InputFormat = JSONLinesInputFormat{
+		KeyField: "count",
+		NewRecord: func() interface{} {
+			return &testJSONRecord{}
+		},
+	}
+	driver := NewDriver(
+		job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithSplitSize(10),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), job.Counters()[MalformedRecordsCounter])
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1\tfoo\n2\tbar\n", string(output))
+}
+
+func TestLocalMapReduceOutputFormat(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input the test"), 0700)
+
+	job1 := NewJob(testWCJob{}, testWCJob{})
+	job1.OutputFormat = JSONLinesOutputFormat{}
+	job2 := NewJob(&testFilterJob{prefix: "t"}, &testFilterJob{})
+	job2.InputFormat = JSONLinesInputFormat{KeyField: "key"}
+	job2.OutputFormat = CSVOutputFormat{Header: []string{"word", "record"}}
+	driver := NewMultiStageDriver(
+		[]*Job{job1, job2},
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+	)
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "job0", "output-part-0.json"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(output), "{\"key\":\"test\",\"value\":\"2\"}\n")
+
+	output, err = ioutil.ReadFile(filepath.Join(tmpdir, "job1", "output-part-0.csv"))
+	assert.Nil(t, err)
+	assert.Equal(t, `word,record
+test,"{""key"":""test"",""value"":""2""}"
+the,"{""key"":""the"",""value"":""2""}"
+`, string(output))
+}
@@ -1,38 +1,52 @@
 package corral
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/bcongdon/corral/internal/pkg/corfs"
-	log "github.com/sirupsen/logrus"
 )
 
 // Emitter enables mappers and reducers to yield key-value pairs.
 type Emitter interface {
 	Emit(key, value string) error
+	// Counter returns the job counter with the given name
+	Counter(name string) *Counter
 	close() error
 	bytesWritten() int64
 }
 
-// reducerEmitter is a threadsafe emitter.
+// reducerEmitter is a threadsafe emitter that writes records using an OutputFormat.
 type reducerEmitter struct {
-	writer       io.WriteCloser
-	mut          *sync.Mutex
-	writtenBytes int64
+	writer   io.WriteCloser
+	counter  *countingWriter
+	records  RecordWriter
+	mut      *sync.Mutex
+	closed   bool
+	counters *counterSet
 }
 
 // newReducerEmitter initializes and returns a new reducerEmitter
-func newReducerEmitter(writer io.WriteCloser) *reducerEmitter {
-	return &reducerEmitter{
-		writer: writer,
-		mut:    &sync.Mutex{},
+func newReducerEmitter(writer io.WriteCloser, format OutputFormat) (*reducerEmitter, error) {
+	counter := &countingWriter{writer: writer}
+	records, err := format.NewRecordWriter(counter)
+	if err != nil {
+		return nil, err
 	}
+
+	return &reducerEmitter{
+		writer:   writer,
+		counter:  counter,
+		records:  records,
+		mut:      &sync.Mutex{},
+		counters: &counterSet{},
+	}, nil
 }
 
 // Emit yields a key-value pair to the framework.
@@ -40,42 +54,78 @@ func (e *reducerEmitter) Emit(key, value string) error {
 	e.mut.Lock()
 	defer e.mut.Unlock()
 
-	n, err := e.writer.Write([]byte(fmt.Sprintf("%s\t%s\n", key, value)))
-	e.writtenBytes += int64(n)
-	return err
+	return e.records.Write(key, value)
+}
+
+// Counter returns the job counter with the given name
+func (e *reducerEmitter) Counter(name string) *Counter {
+	return e.counters.Counter(name)
 }
 
-// close terminates the reducerEmitter. close must not be called more than once
+// close flushes any buffered records, and terminates the reducerEmitter.
+// Calls to close after the first have no effect.
 func (e *reducerEmitter) close() error {
-	return e.writer.Close()
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	err := e.records.Close()
+	if closeErr := e.writer.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
 func (e *reducerEmitter) bytesWritten() int64 {
-	return e.writtenBytes
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	return e.counter.written
 }
 
 // mapperEmitter is an emitter that partitions keys written to it.
 // mapperEmitter maintains a map of writers. Keys are partitioned into one of numBins
 // intermediate "shuffle" bins. Each bin is written as a separate file.
 type mapperEmitter struct {
-	numBins       uint                    // number of intermediate shuffle bins
-	writers       map[uint]io.WriteCloser // maps a parition number to an open writer
-	fs            corfs.FileSystem        // filesystem to use when opening writers
-	mapperID      uint                    // numeric identifier of the mapper using this emitter
-	outDir        string                  // folder to save map output to
-	partitionFunc PartitionFunc           // PartitionFunc to use when partitioning map output keys into intermediate bins
-	writtenBytes  int64                   // counter for number of bytes written from emitted key/val pairs
+	ctx           context.Context              // context that writers are bound to
+	numBins       uint                         // number of intermediate shuffle bins
+	writers       map[uint]*intermediateWriter // maps a parition number to an open writer
+	format        IntermediateFormat           // encoding of intermediate files
+	compression   Compression                  // compression codec of intermediate files
+	fs            corfs.FileSystem             // filesystem to use when opening writers
+	mapperID      uint                         // numeric identifier of the mapper using this emitter
+	outDir        string                       // folder to save map output to
+	partitionFunc PartitionFunc                // PartitionFunc to use when partitioning map output keys into intermediate bins
+	writtenBytes  int64                        // counter for number of bytes written from emitted key/val pairs
+	combiner      Reducer                      // optional Reducer used to pre-aggregate values before they are written
+	maxBufferSize int64                        // maximum size (in bytes) of buffered pairs before they are combined and written
+	buffer        map[string][]string          // values awaiting combination, by key
+	bufferedBytes int64                        // size of the keys and values in buffer
+	hotKeys       map[string]bool              // keys whose values are split between several bins
+	salts         int                          // number of bins that each hot key's values are split between
+	nextSalt      int                          // salt of the next value of a hot key
+	keys          topKeys                      // largest keys written to the shuffle bins
+	binBytes      map[uint]int64               // bytes written to each shuffle bin, once closed
+	counters      *counterSet                  // counters of the map task
 }
 
 // Initializes a new mapperEmitter
-func newMapperEmitter(numBins uint, mapperID uint, outDir string, fs corfs.FileSystem) mapperEmitter {
+func newMapperEmitter(ctx context.Context, numBins uint, mapperID uint, outDir string, fs corfs.FileSystem) mapperEmitter {
 	return mapperEmitter{
+		ctx:           ctx,
 		numBins:       numBins,
-		writers:       make(map[uint]io.WriteCloser, numBins),
+		writers:       make(map[uint]*intermediateWriter, numBins),
+		format:        BinaryFormat,
+		compression:   NoCompression,
 		fs:            fs,
 		mapperID:      mapperID,
 		outDir:        outDir,
 		partitionFunc: hashPartition,
+		counters:      &counterSet{},
 	}
 }
 
@@ -87,16 +137,81 @@ func hashPartition(key string, numBins uint) uint {
 }
 
 // Emit yields a key-value pair to the framework.
+// If the emitter has a combiner, the pair is buffered and combined with other
+// values of the same key before being written.
 func (me *mapperEmitter) Emit(key, value string) error {
-	bin := me.partitionFunc(key, me.numBins)
+	if me.combiner == nil {
+		return me.write(key, value)
+	}
+
+	if me.buffer == nil {
+		me.buffer = make(map[string][]string)
+	}
+	if _, exists := me.buffer[key]; !exists {
+		me.bufferedBytes += int64(len(key))
+	}
+	me.buffer[key] = append(me.buffer[key], value)
+	me.bufferedBytes += int64(len(value))
+
+	if me.bufferedBytes >= me.maxBufferSize {
+		return me.flush()
+	}
+	return nil
+}
+
+// flush runs the combiner over all buffered values and writes its output to the shuffle bins.
+func (me *mapperEmitter) flush() error {
+	keys := make([]string, 0, len(me.buffer))
+	for key := range me.buffer {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	emitter := &combineEmitter{mapperEmitter: me}
+	combine := reduceFunc(me.combiner)
+	for _, key := range keys {
+		values := me.buffer[key]
+		valueChan := make(chan string, len(values))
+		for _, value := range values {
+			valueChan <- value
+		}
+		close(valueChan)
+
+		if err := combine(key, newValueIterator(valueChan), emitter); err != nil && emitter.err == nil {
+			emitter.err = fmt.Errorf("combining key %q: %w", truncate(key, 64), err)
+		}
+		if emitter.err != nil {
+			break
+		}
+	}
+
+	me.buffer = nil
+	me.bufferedBytes = 0
+	return emitter.err
+}
+
+// write partitions a key-value pair and writes it to the corresponding shuffle bin.
+// The values of hot keys are partitioned round-robin by salted keys.
+func (me *mapperEmitter) write(key, value string) error {
+	var bin uint
+	if me.hotKeys[key] {
+		bin = me.partitionFunc(saltedKey(key, me.nextSalt), me.numBins)
+		me.nextSalt = (me.nextSalt + 1) % me.salts
+	} else {
+		bin = me.partitionFunc(key, me.numBins)
+	}
+	me.keys.add(key, int64(len(key)+len(value)))
 
 	// Open writer for the bin, if necessary
 	writer, exists := me.writers[bin]
 	if !exists {
-		var err error
 		path := me.fs.Join(me.outDir, fmt.Sprintf("map-bin%d-%d.out", bin, me.mapperID))
 
-		writer, err = me.fs.OpenWriter(path)
+		file, err := me.fs.OpenWriter(me.ctx, path)
+		if err != nil {
+			return err
+		}
+		writer, err = newIntermediateWriter(file, me.format, me.compression)
 		if err != nil {
 			return err
 		}
@@ -107,26 +222,28 @@ func (me *mapperEmitter) Emit(key, value string) error {
 		Key:   key,
 		Value: value,
 	}
-
-	data, err := json.Marshal(kv)
-	if err != nil {
-		log.Error(err)
-		return err
-	}
-
-	data = append(data, '\n')
-	_, err = writer.Write(data)
-	return err
+	return writer.write(kv)
 }
 
 // close terminates the mapperEmitter. Must not be called more than once
 func (me *mapperEmitter) close() error {
 	errs := make([]string, 0)
-	for _, writer := range me.writers {
-		err := writer.Close()
+	var flushErr error
+	if len(me.buffer) > 0 {
+		flushErr = me.flush()
+	}
+	me.binBytes = make(map[uint]int64, len(me.writers))
+	for bin, writer := range me.writers {
+		err := writer.close()
 		if err != nil {
 			errs = append(errs, err.Error())
 		}
+		me.writtenBytes += writer.bytesWritten()
+		me.binBytes[bin] = writer.bytesWritten()
+	}
+	// The output of a failed flush is incomplete, so its error takes precedence
+	if flushErr != nil {
+		return flushErr
 	}
 	if len(errs) > 0 {
 		return errors.New(strings.Join(errs, "\n"))
@@ -138,3 +255,28 @@ func (me *mapperEmitter) close() error {
 func (me *mapperEmitter) bytesWritten() int64 {
 	return me.writtenBytes
 }
+
+// Counter returns the job counter with the given name
+func (me *mapperEmitter) Counter(name string) *Counter {
+	return me.counters.Counter(name)
+}
+
+// stats returns the stats of the mapper's output. Must be called after close.
+func (me *mapperEmitter) stats() mapperStats {
+	return mapperStats{BinBytes: me.binBytes, TopKeys: me.keys.top(reportedKeys)}
+}
+
+// combineEmitter writes the output of a mapperEmitter's combiner directly to its shuffle bins.
+type combineEmitter struct {
+	*mapperEmitter
+	err error // first error encountered when writing combiner output
+}
+
+// Emit yields a combined key-value pair to the framework.
+func (ce *combineEmitter) Emit(key, value string) error {
+	err := ce.mapperEmitter.write(key, value)
+	if err != nil && ce.err == nil {
+		ce.err = err
+	}
+	return err
+}
@@ -2,9 +2,11 @@ package corral
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -29,9 +31,10 @@ func TestHashPartition(t *testing.T) {
 
 func TestReducerEmitter(t *testing.T) {
 	writer := &testWriteCloser{new(bytes.Buffer)}
-	emitter := newReducerEmitter(writer)
+	emitter, err := newReducerEmitter(writer, TextOutputFormat{})
+	assert.Nil(t, err)
 
-	err := emitter.Emit("key", "value")
+	err = emitter.Emit("key", "value")
 	assert.Nil(t, err)
 
 	written, err := ioutil.ReadAll(writer)
@@ -44,7 +47,8 @@ func TestReducerEmitter(t *testing.T) {
 
 func TestReducerEmitterThreadSafety(t *testing.T) {
 	writer := &testWriteCloser{new(bytes.Buffer)}
-	emitter := newReducerEmitter(writer)
+	emitter, err := newReducerEmitter(writer, TextOutputFormat{})
+	assert.Nil(t, err)
 
 	var wg sync.WaitGroup
 	for i := 0; i < 10; i++ {
@@ -78,11 +82,11 @@ func (m *mockFs) ListFiles(string) ([]corfs.FileInfo, error) {
 	return []corfs.FileInfo{}, nil
 }
 
-func (m *mockFs) OpenReader(filePath string, startAt int64) (io.ReadCloser, error) {
+func (m *mockFs) OpenReader(ctx context.Context, filePath string, startAt int64) (io.ReadCloser, error) {
 	return ioutil.NopCloser(new(bytes.Buffer)), nil
 }
 
-func (m *mockFs) OpenWriter(filePath string) (io.WriteCloser, error) {
+func (m *mockFs) OpenWriter(ctx context.Context, filePath string) (io.WriteCloser, error) {
 	if _, ok := m.writers[filePath]; !ok {
 		buf := new(bytes.Buffer)
 		m.writers[filePath] = &testWriteCloser{buf}
@@ -103,10 +107,15 @@ func (m *mockFs) Join(e ...string) string { return strings.Join(e, "/") }
 
 func (m *mockFs) Delete(string) error { return nil }
 
+func (m *mockFs) DeleteAll(string) error { return nil }
+
+func (m *mockFs) Rename(string, string) error { return nil }
+
 func TestMapperEmitter(t *testing.T) {
 	mFs := &mockFs{writers: make(map[string]*testWriteCloser)}
 	var fs corfs.FileSystem = mFs
-	emitter := newMapperEmitter(3, 0, "out", fs)
+	emitter := newMapperEmitter(context.Background(), 3, 0, "out", fs)
+	emitter.format = JSONFormat
 
 	err := emitter.Emit("key1", "val1")
 	assert.Nil(t, err)
@@ -118,18 +127,19 @@ func TestMapperEmitter(t *testing.T) {
 	assert.Nil(t, err)
 
 	assert.Len(t, mFs.writers, 3)
+	assert.Nil(t, emitter.close())
 
 	assert.Equal(t, `{"key":"key123","value":"val2"}`+"\n", string(mFs.writers["out/map-bin0-0.out"].Bytes()))
 	assert.Equal(t, `{"key":"key359","value":"val3"}`+"\n", string(mFs.writers["out/map-bin1-0.out"].Bytes()))
 	assert.Equal(t, `{"key":"key1","value":"val1"}`+"\n", string(mFs.writers["out/map-bin2-0.out"].Bytes()))
-
-	assert.Nil(t, emitter.close())
+	assert.Equal(t, int64(94), emitter.bytesWritten())
 }
 
 func TestMapperEmitterCustomPartition(t *testing.T) {
 	mFs := &mockFs{writers: make(map[string]*testWriteCloser)}
 	var fs corfs.FileSystem = mFs
-	emitter := newMapperEmitter(3, 0, "out", fs)
+	emitter := newMapperEmitter(context.Background(), 3, 0, "out", fs)
+	emitter.format = JSONFormat
 	emitter.partitionFunc = func(key string, numBuckets uint) uint {
 		if strings.HasPrefix(key, "a") {
 			return 0
@@ -147,9 +157,68 @@ func TestMapperEmitterCustomPartition(t *testing.T) {
 	assert.Nil(t, err)
 
 	assert.Len(t, mFs.writers, 2)
+	assert.Nil(t, emitter.close())
 
 	assert.Equal(t, `{"key":"a","value":"val1"}`+"\n"+`{"key":"a","value":"val2"}`+"\n", string(mFs.writers["out/map-bin0-0.out"].Bytes()))
 	assert.Equal(t, `{"key":"b","value":"val3"}`+"\n", string(mFs.writers["out/map-bin2-0.out"].Bytes()))
+}
+
+type testSumCombiner struct{}
+
+func (testSumCombiner) Reduce(key string, values ValueIterator, emitter Emitter) {
+	sum := 0
+	for value := range values.Iter() {
+		n, _ := strconv.Atoi(value)
+		sum += n
+	}
+	emitter.Emit(key, strconv.Itoa(sum))
+}
+
+func TestMapperEmitterCombiner(t *testing.T) {
+	mFs := &mockFs{writers: make(map[string]*testWriteCloser)}
+	emitter := newMapperEmitter(context.Background(), 1, 0, "out", mFs)
+	emitter.format = JSONFormat
+	emitter.combiner = testSumCombiner{}
+	emitter.maxBufferSize = 1024
+
+	for _, key := range []string{"b", "a", "b", "b"} {
+		err := emitter.Emit(key, "1")
+		assert.Nil(t, err)
+	}
+
+	// Values are buffered until the emitter is closed
+	assert.Len(t, mFs.writers, 0)
+	assert.Nil(t, emitter.close())
+
+	assert.Equal(t, `{"key":"a","value":"1"}`+"\n"+`{"key":"b","value":"3"}`+"\n", string(mFs.writers["out/map-bin0-0.out"].Bytes()))
+}
+
+func TestMapperEmitterCombinerBufferLimit(t *testing.T) {
+	mFs := &mockFs{writers: make(map[string]*testWriteCloser)}
+	emitter := newMapperEmitter(context.Background(), 1, 0, "out", mFs)
+	emitter.format = JSONFormat
+	emitter.combiner = testSumCombiner{}
+	emitter.maxBufferSize = 4
+
+	// The key is counted once and each value adds 1 byte, so the buffer is flushed on the third emit
+	for i := 0; i < 4; i++ {
+		err := emitter.Emit("a", "1")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int64(2), emitter.bufferedBytes)
+	assert.Nil(t, emitter.close())
+
+	assert.Equal(t, `{"key":"a","value":"3"}`+"\n"+`{"key":"a","value":"1"}`+"\n", string(mFs.writers["out/map-bin0-0.out"].Bytes()))
+}
+
+func TestMapperEmitterBinaryFormat(t *testing.T) {
+	mFs := &mockFs{writers: make(map[string]*testWriteCloser)}
+	emitter := newMapperEmitter(context.Background(), 1, 0, "out", mFs)
 
+	err := emitter.Emit("key", "value")
+	assert.Nil(t, err)
 	assert.Nil(t, emitter.close())
+
+	assert.Equal(t, "CRLB\x01\x03key\x05value", string(mFs.writers["out/map-bin0-0.out"].Bytes()))
+	assert.Equal(t, int64(15), emitter.bytesWritten())
 }
@@ -0,0 +1,131 @@
+package corral
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrMalformedRecord is wrapped by errors that RecordReaders (or Mappers) return for malformed
+// input records. Malformed records are skipped (and counted), rather than failing the map task.
+var ErrMalformedRecord = errors.New("Malformed input record")
+
+// RecordError describes the failure to read or map an input record. Offset is the byte
+// offset of the record within its (decompressed) file, or -1 if it isn't known.
+type RecordError struct {
+	Filename string
+	Offset   int64
+	Err      error
+}
+
+func (e *RecordError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("%s: %s", e.Filename, e.Err)
+	}
+	return fmt.Sprintf("%s (offset %d): %s", e.Filename, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying record error.
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// offsetRecordReader is implemented by RecordReaders that know the file offset of the
+// last record that they read
+type offsetRecordReader interface {
+	recordOffset() int64
+}
+
+// newRecordError returns a RecordError for the failure of the last record read from records
+func newRecordError(split InputSplit, records RecordReader, err error) *RecordError {
+	offset := int64(-1)
+	if reader, ok := records.(offsetRecordReader); ok {
+		offset = reader.recordOffset()
+	}
+	return &RecordError{Filename: split.Filename, Offset: offset, Err: err}
+}
+
+// TaskError describes the failure of a single map or reduce task.
+// Attempts is the number of times the task was run before it failed.
+type TaskError struct {
+	JobNumber int
+	Phase     Phase
+	BinID     uint
+	Attempts  int
+	Err       error
+}
+
+func (e *TaskError) Error() string {
+	return fmt.Sprintf("%s bin %d: %s", e.Phase, e.BinID, e.Err)
+}
+
+// Unwrap returns the underlying task error.
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// JobError is returned when one or more tasks in a phase of a job fail.
+// JobName is the job's Name, or "" if it wasn't named.
+// Tasks lists every failed task, ordered by bin ID.
+type JobError struct {
+	JobNumber int
+	JobName   string
+	Phase     Phase
+	Tasks     []*TaskError
+}
+
+func (e *JobError) Error() string {
+	failures := make([]string, len(e.Tasks))
+	for i, task := range e.Tasks {
+		failures[i] = task.Error()
+	}
+	name := e.JobName
+	if name == "" {
+		name = fmt.Sprintf("job%d", e.JobNumber)
+	}
+	return fmt.Sprintf("%s: %d %s task(s) failed: %s", name, len(e.Tasks), e.Phase, strings.Join(failures, "; "))
+}
+
+// Unwrap returns the error of the first failed task.
+func (e *JobError) Unwrap() error {
+	if len(e.Tasks) == 0 {
+		return nil
+	}
+	return e.Tasks[0]
+}
+
+// taskFailures collects the failed tasks of a single phase. It is safe for concurrent use.
+type taskFailures struct {
+	mut   sync.Mutex
+	tasks []*TaskError
+}
+
+func (f *taskFailures) add(err *TaskError) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.tasks = append(f.tasks, err)
+}
+
+// err returns a JobError describing all collected failures, or nil if no task failed.
+func (f *taskFailures) err(jobNumber int, jobName string, phase Phase) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if len(f.tasks) == 0 {
+		return nil
+	}
+
+	tasks := make([]*TaskError, len(f.tasks))
+	copy(tasks, f.tasks)
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].BinID < tasks[j].BinID
+	})
+	return &JobError{
+		JobNumber: jobNumber,
+		JobName:   jobName,
+		Phase:     phase,
+		Tasks:     tasks,
+	}
+}
@@ -0,0 +1,55 @@
+package corral
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskFailures(t *testing.T) {
+	var failures taskFailures
+	assert.Nil(t, failures.err(0, "", MapPhase))
+
+	failures.add(&TaskError{JobNumber: 1, Phase: ReducePhase, BinID: 3, Err: errors.New("foo")})
+	failures.add(&TaskError{JobNumber: 1, Phase: ReducePhase, BinID: 1, Err: errors.New("bar")})
+
+	err := failures.err(1, "", ReducePhase)
+	jobErr, ok := err.(*JobError)
+	assert.True(t, ok)
+	assert.Len(t, jobErr.Tasks, 2)
+	assert.Equal(t, uint(1), jobErr.Tasks[0].BinID)
+	assert.Equal(t, uint(3), jobErr.Tasks[1].BinID)
+	assert.Equal(t, "job1: 2 reduce task(s) failed: reduce bin 1: bar; reduce bin 3: foo", err.Error())
+
+	// Named jobs are identified by their name
+	err = failures.err(1, "wordcount", ReducePhase)
+	assert.Equal(t, "wordcount: 2 reduce task(s) failed: reduce bin 1: bar; reduce bin 3: foo", err.Error())
+}
+
+func TestJobErrorUnwrap(t *testing.T) {
+	cause := errors.New("cause")
+	err := &JobError{
+		Tasks: []*TaskError{{Phase: MapPhase, Err: cause}},
+	}
+	assert.True(t, errors.Is(err, cause))
+}
+
+func TestRecordError(t *testing.T) {
+	err := &RecordError{Filename: "input.txt", Offset: 42, Err: errors.New("foo")}
+	assert.EqualError(t, err, "input.txt (offset 42): foo")
+	assert.Equal(t, "foo", errors.Unwrap(err).Error())
+
+	err.Offset = -1
+	assert.EqualError(t, err, "input.txt: foo")
+
+	assert.Equal(t, int64(-1), newRecordError(InputSplit{}, testRecordReader{}, err).Offset)
+}
+
+// testRecordReader is a RecordReader that doesn't know the offsets of its records
+type testRecordReader struct{}
+
+func (testRecordReader) Next() (string, string, error) {
+	return "", "", io.EOF
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 
@@ -12,28 +11,37 @@ const pageRankCutoff = 50
 
 type amplab1 struct{}
 
-func (a amplab1) Map(key, value string, emitter corral.Emitter) {
-	fields := strings.Split(value, ",")
+func (a amplab1) Map(key, value string, emitter corral.Emitter) error {
+	return a.MapFields(nil, strings.Split(value, ","), emitter)
+}
+
+// MapFields receives the fields of each CSV input record
+func (a amplab1) MapFields(header, fields []string, emitter corral.Emitter) error {
 	if len(fields) != 3 {
-		fmt.Printf("Invalid record: '%s'\n", value)
-		return
+		emitter.Counter("invalid_records").Inc()
+		return nil
 	}
 
 	pageURL := fields[0]
 	pageRank, err := strconv.Atoi(fields[1])
 	if err == nil && pageRank > pageRankCutoff {
-		emitter.Emit(pageURL, fields[1])
+		return emitter.Emit(pageURL, fields[1])
 	}
+	return nil
 }
 
-func (a amplab1) Reduce(key string, values corral.ValueIterator, emitter corral.Emitter) {
+func (a amplab1) Reduce(key string, values corral.ValueIterator, emitter corral.Emitter) error {
 	for value := range values.Iter() {
-		emitter.Emit(key, value)
+		if err := emitter.Emit(key, value); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func main() {
-	job := corral.NewJob(amplab1{}, amplab1{})
+	job := corral.NewJob(corral.AdaptMapperE(amplab1{}), corral.AdaptReducerE(amplab1{}))
+	job.InputFormat = corral.CSVInputFormat{SingleLineRecords: true}
 
 	driver := corral.NewDriver(job)
 	driver.Main()
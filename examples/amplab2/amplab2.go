@@ -19,19 +19,23 @@ func min(a, b int) int {
 	return b
 }
 
-func (a amplab2) Map(key, value string, emitter corral.Emitter) {
-	fields := strings.Split(value, ",")
+func (a amplab2) Map(key, value string, emitter corral.Emitter) error {
+	return a.MapFields(nil, strings.Split(value, ","), emitter)
+}
+
+// MapFields receives the fields of each CSV input record
+func (a amplab2) MapFields(header, fields []string, emitter corral.Emitter) error {
 	if len(fields) != 9 {
-		fmt.Printf("Invalid record: '%s'\n", value)
-		return
+		emitter.Counter("invalid_records").Inc()
+		return nil
 	}
 
 	sourceIP := fields[0]
 	adRevenue := fields[3]
-	emitter.Emit(sourceIP[:min(subStrX, len(sourceIP))], adRevenue)
+	return emitter.Emit(sourceIP[:min(subStrX, len(sourceIP))], adRevenue)
 }
 
-func (a amplab2) Reduce(key string, values corral.ValueIterator, emitter corral.Emitter) {
+func (a amplab2) Reduce(key string, values corral.ValueIterator, emitter corral.Emitter) error {
 	totalRevenue := 0.0
 	for value := range values.Iter() {
 		adRevenue, err := strconv.ParseFloat(value, 64)
@@ -39,11 +43,12 @@ func (a amplab2) Reduce(key string, values corral.ValueIterator, emitter corral.
 			totalRevenue += adRevenue
 		}
 	}
-	emitter.Emit(key, fmt.Sprintf("%f", totalRevenue))
+	return emitter.Emit(key, fmt.Sprintf("%f", totalRevenue))
 }
 
 func main() {
-	job := corral.NewJob(amplab2{}, amplab2{})
+	job := corral.NewJob(corral.AdaptMapperE(amplab2{}), corral.AdaptReducerE(amplab2{}))
+	job.InputFormat = corral.CSVInputFormat{SingleLineRecords: true}
 
 	driver := corral.NewDriver(job)
 	driver.Main()
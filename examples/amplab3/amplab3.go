@@ -33,10 +33,13 @@ type Record struct {
 }
 
 // Map receives input lines from both "UserVisit" and "Ranking" datasets.
-// It parses the line into a record. It filters by visit date (in the case of "UserVisit").
-func (a amplab3Join) Map(key, value string, emitter corral.Emitter) {
-	fields := strings.Split(value, ",")
+func (a amplab3Join) Map(key, value string, emitter corral.Emitter) error {
+	return a.MapFields(nil, strings.Split(value, ","), emitter)
+}
 
+// MapFields receives the fields of CSV records from both "UserVisit" and "Ranking" datasets.
+// It parses the fields into a record. It filters by visit date (in the case of "UserVisit").
+func (a amplab3Join) MapFields(header, fields []string, emitter corral.Emitter) error {
 	switch len(fields) {
 	case 3: // Rankings Record
 		pageRank, _ := strconv.Atoi(fields[1])
@@ -45,7 +48,7 @@ func (a amplab3Join) Map(key, value string, emitter corral.Emitter) {
 			PageURL:    fields[0],
 			PageRank:   pageRank,
 		}
-		emitRecord(ranking.PageURL, ranking, emitter)
+		return emitRecord(ranking.PageURL, ranking, emitter)
 	case 9: // Visits record
 		adRevenue, _ := strconv.ParseFloat(fields[3], 64)
 		visit := Record{
@@ -56,58 +59,70 @@ func (a amplab3Join) Map(key, value string, emitter corral.Emitter) {
 		}
 		date, err := time.Parse(dateFormat, fields[2])
 		if err != nil {
-			fmt.Println(err)
+			emitter.Counter("invalid_dates").Inc()
 		}
 		if date.Before(cutoffDate) {
-			emitRecord(visit.DestURL, visit, emitter)
+			return emitRecord(visit.DestURL, visit, emitter)
 		}
 	default:
-		fmt.Printf("Invalid record: '%s'\n", value)
-		return
+		emitter.Counter("invalid_records").Inc()
 	}
+	return nil
 }
 
 func emitRecord(key string, record Record, emitter corral.Emitter) error {
-	payload, _ := json.Marshal(record)
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
 	return emitter.Emit(key, string(payload))
 }
 
-func (a amplab3Join) Reduce(URL string, values corral.ValueIterator, emitter corral.Emitter) {
+func (a amplab3Join) Reduce(URL string, values corral.ValueIterator, emitter corral.Emitter) error {
 	bufferedVisits := make([]Record, 0)
 	var matchingRank *Record
 
 	for value := range values.Iter() {
 		var record Record
-		json.Unmarshal([]byte(value), &record)
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			return err
+		}
 
 		if record.RecordType == rankingType {
 			matchingRank = &record
 			for _, visit := range bufferedVisits {
 				visit.PageRank = matchingRank.PageRank
-				emitRecord(visit.SourceIP, visit, emitter)
+				if err := emitRecord(visit.SourceIP, visit, emitter); err != nil {
+					return err
+				}
 			}
 			bufferedVisits = nil
 		} else if matchingRank != nil {
 			record.PageRank = matchingRank.PageRank
-			emitRecord(record.SourceIP, record, emitter)
+			if err := emitRecord(record.SourceIP, record, emitter); err != nil {
+				return err
+			}
 		} else {
 			bufferedVisits = append(bufferedVisits, record)
 		}
 	}
+	return nil
 }
 
-func (amplab3Aggregate) Map(key, value string, emitter corral.Emitter) {
-	emitter.Emit(key, value)
+func (amplab3Aggregate) Map(key, value string, emitter corral.Emitter) error {
+	return emitter.Emit(key, value)
 }
 
-func (amplab3Aggregate) Reduce(sourceIP string, values corral.ValueIterator, emitter corral.Emitter) {
+func (amplab3Aggregate) Reduce(sourceIP string, values corral.ValueIterator, emitter corral.Emitter) error {
 	sumPageRank := 0
 	sumAdRevenue := 0.0
 	count := 0
 
 	for value := range values.Iter() {
 		var record Record
-		json.Unmarshal([]byte(value), &record)
+		if err := json.Unmarshal([]byte(value), &record); err != nil {
+			return err
+		}
 
 		sumPageRank += record.PageRank
 		sumAdRevenue += record.AdRevenue
@@ -116,12 +131,13 @@ func (amplab3Aggregate) Reduce(sourceIP string, values corral.ValueIterator, emi
 
 	avgPageRank := float64(sumPageRank) / float64(count)
 	avgAdRevenue := sumAdRevenue / float64(count)
-	emitter.Emit(sourceIP, fmt.Sprintf("%f\t%f", avgPageRank, avgAdRevenue))
+	return emitter.Emit(sourceIP, fmt.Sprintf("%f\t%f", avgPageRank, avgAdRevenue))
 }
 
 func main() {
-	job1 := corral.NewJob(amplab3Join{}, amplab3Join{})
-	job2 := corral.NewJob(amplab3Aggregate{}, amplab3Aggregate{})
+	job1 := corral.NewJob(corral.AdaptMapperE(amplab3Join{}), corral.AdaptReducerE(amplab3Join{}))
+	job1.InputFormat = corral.CSVInputFormat{SingleLineRecords: true}
+	job2 := corral.NewJob(corral.AdaptMapperE(amplab3Aggregate{}), corral.AdaptReducerE(amplab3Aggregate{}))
 
 	driver := corral.NewMultiStageDriver(
 		[]*corral.Job{job1, job2},
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -11,7 +10,7 @@ import (
 
 type wordCount struct{}
 
-func (w wordCount) Map(key, value string, emitter corral.Emitter) {
+func (w wordCount) Map(key, value string, emitter corral.Emitter) error {
 	re := regexp.MustCompile("[^a-zA-Z0-9\\s]+")
 
 	sanitized := strings.ToLower(re.ReplaceAllString(value, " "))
@@ -19,23 +18,23 @@ func (w wordCount) Map(key, value string, emitter corral.Emitter) {
 		if len(word) == 0 {
 			continue
 		}
-		err := emitter.Emit(word, strconv.Itoa(1))
-		if err != nil {
-			fmt.Println(err)
+		if err := emitter.Emit(word, strconv.Itoa(1)); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
-func (w wordCount) Reduce(key string, values corral.ValueIterator, emitter corral.Emitter) {
+func (w wordCount) Reduce(key string, values corral.ValueIterator, emitter corral.Emitter) error {
 	count := 0
 	for range values.Iter() {
 		count++
 	}
-	emitter.Emit(key, strconv.Itoa(count))
+	return emitter.Emit(key, strconv.Itoa(count))
 }
 
 func main() {
-	job := corral.NewJob(wordCount{}, wordCount{})
+	job := corral.NewJob(corral.AdaptMapperE(wordCount{}), corral.AdaptReducerE(wordCount{}))
 
 	options := []corral.Option{
 		corral.WithSplitSize(10 * 1024),
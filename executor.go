@@ -1,16 +1,20 @@
 package corral
 
+import "context"
+
+// executor runs map and reduce tasks. Each run of a task is identified by an attempt
+// number, which scopes the paths that the task writes its (uncommitted) output to.
 type executor interface {
-	RunMapper(job *Job, jobNumber int, binID uint, inputSplits []inputSplit) error
-	RunReducer(job *Job, jobNumber int, binID uint) error
+	RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error
+	RunReducer(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int) error
 }
 
 type localExecutor struct{}
 
-func (localExecutor) RunMapper(job *Job, jobNumber int, binID uint, inputSplits []inputSplit) error {
-	return job.runMapper(binID, inputSplits)
+func (localExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	return job.runMapper(ctx, binID, attempt, inputSplits)
 }
 
-func (localExecutor) RunReducer(job *Job, jobNumber int, binID uint) error {
-	return job.runReducer(binID)
+func (localExecutor) RunReducer(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int) error {
+	return job.runReducer(ctx, binID, attempt)
 }
@@ -6,6 +6,7 @@ require (
 	github.com/aws/aws-lambda-go v1.24.0
 	github.com/aws/aws-sdk-go v1.38.45
 	github.com/dustin/go-humanize v1.0.0
+	github.com/golang/snappy v0.0.4
 	github.com/hashicorp/golang-lru v0.5.4
 	github.com/mattetti/filebuffer v1.0.1
 	github.com/mattn/go-runewidth v0.0.12 // indirect
@@ -0,0 +1,348 @@
+package corral
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+// InputFormat controls how input files are divided into splits, and how
+// the records of each split are read and passed to the mapper.
+type InputFormat interface {
+	// Splittable returns true if the named file may be divided into multiple InputSplits.
+	// Files that aren't splittable are read in their entirety by a single mapper.
+	Splittable(filename string) bool
+	// NewRecordReader returns a RecordReader over the records of split.
+	// r reads the split's file starting at split.StartOffset, and continues past the end of
+	// the split, so that records which span split boundaries can be read in full.
+	// Compressed input files are never split: for these, r reads the decompressed file
+	// and split spans the entirety of it.
+	NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error)
+}
+
+// RecordReader reads the key/value records of a single InputSplit.
+// Each record in an input file must be read by exactly one RecordReader, so
+// readers are responsible for handling records that span split boundaries.
+type RecordReader interface {
+	// Next returns the next record in the split, or io.EOF when there are no more records.
+	Next() (key, value string, err error)
+}
+
+// FieldsRecordReader is implemented by RecordReaders of delimited records that
+// can pass parsed fields to a FieldsMapper.
+type FieldsRecordReader interface {
+	RecordReader
+	// NextFields returns the header row of the split's file (or nil), and the fields
+	// of the next record in the split. It returns io.EOF when there are no more records.
+	NextFields() (header, fields []string, err error)
+}
+
+// DecodedRecordReader is implemented by RecordReaders of structured records that
+// can pass decoded records to a RecordMapper.
+type DecodedRecordReader interface {
+	RecordReader
+	// NextRecord returns the key and decoded value of the next record in the split.
+	// It returns io.EOF when there are no more records.
+	NextRecord() (key string, record interface{}, err error)
+}
+
+// headerInputFormat is implemented by InputFormats that read a header from the start
+// of each file. Readers of splits that don't begin at the start of a file are given
+// a second reader of the file, from which to read its header.
+type headerInputFormat interface {
+	InputFormat
+	hasHeader() bool
+	newHeaderRecordReader(split InputSplit, r io.Reader, header io.Reader) (RecordReader, error)
+}
+
+// splitAligner is implemented by InputFormats whose record boundaries can't be found
+// from an arbitrary offset, and so must adjust the boundaries of a file's splits before
+// they're read.
+type splitAligner interface {
+	alignSplits(ctx context.Context, fs corfs.FileSystem, splits []InputSplit) ([]InputSplit, error)
+}
+
+// TextInputFormat reads newline-delimited records. Lines containing exactly one tab
+// are split into a key and value; all other lines are passed to the mapper as a value
+// with an empty key. This is the default InputFormat.
+type TextInputFormat struct{}
+
+// Splittable returns true for all files
+func (TextInputFormat) Splittable(filename string) bool {
+	return true
+}
+
+// NewRecordReader returns a RecordReader over the lines of split
+func (TextInputFormat) NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error) {
+	return newDelimitedRecordReader(split, r, bufio.ScanLines, 1, func(offset int64, record string) (string, string) {
+		kv := splitInputRecord(record)
+		return kv.Key, kv.Value
+	}), nil
+}
+
+// LineInputFormat reads newline-delimited records. Each line is passed to the mapper
+// as a value, keyed by the (decimal) byte offset of the line within its file.
+type LineInputFormat struct{}
+
+// Splittable returns true for all files
+func (LineInputFormat) Splittable(filename string) bool {
+	return true
+}
+
+// NewRecordReader returns a RecordReader over the lines of split
+func (LineInputFormat) NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error) {
+	return newDelimitedRecordReader(split, r, bufio.ScanLines, 1, func(offset int64, record string) (string, string) {
+		return strconv.FormatInt(offset, 10), record
+	}), nil
+}
+
+// DelimitedInputFormat reads records separated by a custom delimiter.
+// Records can only be reliably found from the middle of a file if the delimiter cannot
+// overlap with itself, so files are not split when a proper prefix of RecordDelimiter
+// is also a suffix of it (e.g. "||" or "\n\n").
+type DelimitedInputFormat struct {
+	// RecordDelimiter separates records. It must not be empty.
+	RecordDelimiter string
+	// KeyValueSeparator optionally separates the key of each record from its value.
+	// Records are split on the first occurrence of KeyValueSeparator; records that don't
+	// contain it (or all records, if it is empty) are passed to the mapper with an empty key.
+	KeyValueSeparator string
+}
+
+// Splittable returns true if RecordDelimiter cannot overlap with itself
+func (d DelimitedInputFormat) Splittable(filename string) bool {
+	for i := 1; i < len(d.RecordDelimiter); i++ {
+		if strings.HasPrefix(d.RecordDelimiter, d.RecordDelimiter[i:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRecordReader returns a RecordReader over the delimited records of split
+func (d DelimitedInputFormat) NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error) {
+	if d.RecordDelimiter == "" {
+		return nil, errors.New("DelimitedInputFormat requires a RecordDelimiter")
+	}
+
+	return newDelimitedRecordReader(split, r, scanDelimited([]byte(d.RecordDelimiter)), len(d.RecordDelimiter), func(offset int64, record string) (string, string) {
+		if d.KeyValueSeparator != "" {
+			if idx := strings.Index(record, d.KeyValueSeparator); idx >= 0 {
+				return record[:idx], record[idx+len(d.KeyValueSeparator):]
+			}
+		}
+		return "", record
+	}), nil
+}
+
+// scanDelimited returns a bufio.SplitFunc that splits data on delimiter
+func scanDelimited(delimiter []byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.Index(data, delimiter); i >= 0 {
+			return i + len(delimiter), data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// delimitedRecordReader reads the records of a split that are separated by a delimiter.
+// A record belongs to the split in which its preceding delimiter begins (or the first split,
+// for the first record of a file). So, readers of splits other than the first skip the
+// (possibly partial) record up to the first delimiter, and every reader continues past
+// the end of its split to read the record that begins with a delimiter inside of it.
+type delimitedRecordReader struct {
+	scanner       *bufio.Scanner
+	split         InputSplit
+	delimiterSize int64
+	parse         func(offset int64, record string) (key, value string)
+	bytesRead     int64
+	offset        int64 // file offset of the last record read
+	started       bool
+	done          bool
+}
+
+func newDelimitedRecordReader(split InputSplit, r io.Reader, splitFunc bufio.SplitFunc, delimiterSize int, parse func(int64, string) (string, string)) *delimitedRecordReader {
+	reader := &delimitedRecordReader{
+		scanner:       bufio.NewScanner(r),
+		split:         split,
+		delimiterSize: int64(delimiterSize),
+		parse:         parse,
+	}
+	reader.scanner.Split(countingSplitFunc(splitFunc, &reader.bytesRead))
+	return reader
+}
+
+func (d *delimitedRecordReader) Next() (string, string, error) {
+	if !d.started {
+		d.started = true
+		if d.split.StartOffset != 0 {
+			d.done = !d.scanner.Scan() || d.pastSplitEnd()
+		}
+	}
+
+	recordStart := d.bytesRead
+	d.offset = d.split.StartOffset + recordStart
+	if d.done || !d.scanner.Scan() {
+		d.done = true
+		if err := d.scanner.Err(); err != nil {
+			return "", "", err
+		}
+		return "", "", io.EOF
+	}
+	d.done = d.pastSplitEnd()
+
+	key, value := d.parse(d.offset, d.scanner.Text())
+	return key, value, nil
+}
+
+func (d *delimitedRecordReader) recordOffset() int64 {
+	return d.offset
+}
+
+// pastSplitEnd returns true if the delimiter that ends the last scanned record begins
+// past the end of the split, in which case the next record belongs to another split.
+func (d *delimitedRecordReader) pastSplitEnd() bool {
+	return d.bytesRead-d.delimiterSize >= d.split.Size()
+}
+
+// FixedWidthInputFormat reads fixed-size binary records. Each record is RecordSize
+// bytes long; the first KeySize bytes of a record are its key, and the remaining
+// bytes are its value.
+type FixedWidthInputFormat struct {
+	RecordSize int
+	KeySize    int
+}
+
+// Splittable returns true for all files
+func (FixedWidthInputFormat) Splittable(filename string) bool {
+	return true
+}
+
+// NewRecordReader returns a RecordReader over the fixed-width records of split
+func (f FixedWidthInputFormat) NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error) {
+	if f.RecordSize <= 0 {
+		return nil, errors.New("FixedWidthInputFormat requires a positive RecordSize")
+	}
+	if f.KeySize < 0 || f.KeySize > f.RecordSize {
+		return nil, fmt.Errorf("FixedWidthInputFormat KeySize must be in the range [0, %d]", f.RecordSize)
+	}
+
+	return &fixedWidthRecordReader{
+		reader: bufio.NewReader(r),
+		split:  split,
+		format: f,
+	}, nil
+}
+
+// fixedWidthRecordReader reads the fixed-width records that begin within a split
+type fixedWidthRecordReader struct {
+	reader      *bufio.Reader
+	split       InputSplit
+	format      FixedWidthInputFormat
+	offset      int64
+	recordStart int64 // file offset of the last record read
+	started     bool
+}
+
+func (f *fixedWidthRecordReader) Next() (string, string, error) {
+	recordSize := int64(f.format.RecordSize)
+	if !f.started {
+		f.started = true
+		// Skip to the first record that begins within the split
+		f.offset = f.split.StartOffset
+		if skip := (recordSize - f.offset%recordSize) % recordSize; skip > 0 {
+			n, err := io.CopyN(ioutil.Discard, f.reader, skip)
+			f.offset += n
+			if err != nil {
+				return "", "", err
+			}
+		}
+	}
+
+	if f.offset > f.split.EndOffset {
+		return "", "", io.EOF
+	}
+
+	record := make([]byte, recordSize)
+	f.recordStart = f.offset
+	n, err := io.ReadFull(f.reader, record)
+	f.offset += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		return "", "", fmt.Errorf("Truncated %d byte record at offset %d", n, f.offset-int64(n))
+	} else if err != nil {
+		return "", "", err
+	}
+
+	return string(record[:f.format.KeySize]), string(record[f.format.KeySize:]), nil
+}
+
+func (f *fixedWidthRecordReader) recordOffset() int64 {
+	return f.recordStart
+}
+
+// WholeFileInputFormat reads each input file as a single record, keyed by the file's name.
+// Files are never split, so each file must fit in the memory of a mapper.
+type WholeFileInputFormat struct{}
+
+// Splittable returns false for all files
+func (WholeFileInputFormat) Splittable(filename string) bool {
+	return false
+}
+
+// NewRecordReader returns a RecordReader over the single record of split
+func (WholeFileInputFormat) NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error) {
+	return &wholeFileRecordReader{
+		reader: r,
+		split:  split,
+	}, nil
+}
+
+// wholeFileRecordReader reads an entire file as a single record
+type wholeFileRecordReader struct {
+	reader io.Reader
+	split  InputSplit
+	done   bool
+}
+
+func (w *wholeFileRecordReader) Next() (string, string, error) {
+	if w.done {
+		return "", "", io.EOF
+	}
+	w.done = true
+
+	contents, err := ioutil.ReadAll(w.reader)
+	if err != nil {
+		return "", "", err
+	}
+	return w.split.Filename, string(contents), nil
+}
+
+func (w *wholeFileRecordReader) recordOffset() int64 {
+	return 0
+}
+
+// countingReader wraps an io.Reader and counts the number of bytes read from it
+type countingReader struct {
+	reader io.Reader
+	read   int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.read += int64(n)
+	return n, err
+}
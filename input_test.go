@@ -0,0 +1,134 @@
+package corral
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+	"github.com/stretchr/testify/assert"
+)
+
+// readSplits reads every record of data, divided into splits of at most splitSize bytes
+func readSplits(t *testing.T, format InputFormat, data string, splitSize int64) []keyValue {
+	fInfo := corfs.FileInfo{Name: "input", Size: int64(len(data))}
+	splits := splitInputFile(fInfo, splitSize)
+	if !format.Splittable(fInfo.Name) {
+		splits = wholeFileSplit(fInfo)
+	}
+
+	records := make([]keyValue, 0)
+	for _, split := range splits {
+		reader, err := format.NewRecordReader(split, bytes.NewReader([]byte(data[split.StartOffset:])))
+		assert.Nil(t, err)
+
+		for {
+			key, value, err := reader.Next()
+			if err == io.EOF {
+				break
+			} else if errors.Is(err, ErrMalformedRecord) {
+				continue
+			}
+			assert.Nil(t, err)
+			if err != nil {
+				break
+			}
+			records = append(records, keyValue{key, value})
+		}
+	}
+	return records
+}
+
+func testInputFormat(t *testing.T, format InputFormat, data string, expected []keyValue) {
+	for splitSize := int64(1); splitSize <= int64(len(data))+1; splitSize++ {
+		records := readSplits(t, format, data, splitSize)
+		assert.Equal(t, expected, records, fmt.Sprintf("split size: %d", splitSize))
+	}
+}
+
+func TestTextInputFormat(t *testing.T) {
+	testInputFormat(t, TextInputFormat{}, "foo\tbar\nbaz\n\nkey\tvalue\ttoo\nlast", []keyValue{
+		{"foo", "bar"},
+		{"", "baz"},
+		{"", ""},
+		{"", "key\tvalue\ttoo"},
+		{"", "last"},
+	})
+}
+
+func TestLineInputFormat(t *testing.T) {
+	testInputFormat(t, LineInputFormat{}, "foo\tbar\r\nbaz\n\nlast\n", []keyValue{
+		{"0", "foo\tbar"},
+		{"9", "baz"},
+		{"13", ""},
+		{"14", "last"},
+	})
+}
+
+func TestDelimitedInputFormat(t *testing.T) {
+	format := DelimitedInputFormat{
+		RecordDelimiter:   "|;",
+		KeyValueSeparator: "=",
+	}
+	assert.True(t, format.Splittable("input"))
+	testInputFormat(t, format, "a=1|;b=2=3|;c|;|;d=", []keyValue{
+		{"a", "1"},
+		{"b", "2=3"},
+		{"", "c"},
+		{"", ""},
+		{"d", ""},
+	})
+
+	// Self-overlapping delimiters aren't splittable
+	format.RecordDelimiter = "||"
+	assert.False(t, format.Splittable("input"))
+	testInputFormat(t, format, "a=1||b=2=3||c|||||d=", []keyValue{
+		{"a", "1"},
+		{"b", "2=3"},
+		{"", "c"},
+		{"", ""},
+		{"|d", ""},
+	})
+
+	_, err := DelimitedInputFormat{}.NewRecordReader(InputSplit{}, bytes.NewReader(nil))
+	assert.NotNil(t, err)
+}
+
+func TestFixedWidthInputFormat(t *testing.T) {
+	format := FixedWidthInputFormat{
+		RecordSize: 4,
+		KeySize:    1,
+	}
+	testInputFormat(t, format, "a123b456c\x00\x01\x02", []keyValue{
+		{"a", "123"},
+		{"b", "456"},
+		{"c", "\x00\x01\x02"},
+	})
+
+	_, err := FixedWidthInputFormat{}.NewRecordReader(InputSplit{}, bytes.NewReader(nil))
+	assert.NotNil(t, err)
+	_, err = FixedWidthInputFormat{RecordSize: 1, KeySize: 2}.NewRecordReader(InputSplit{}, bytes.NewReader(nil))
+	assert.NotNil(t, err)
+}
+
+func TestFixedWidthInputFormatTruncated(t *testing.T) {
+	format := FixedWidthInputFormat{RecordSize: 4}
+	reader, err := format.NewRecordReader(InputSplit{StartOffset: 0, EndOffset: 5}, bytes.NewReader([]byte("abcdef")))
+	assert.Nil(t, err)
+
+	_, value, err := reader.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "abcd", value)
+
+	_, _, err = reader.Next()
+	assert.NotNil(t, err)
+}
+
+func TestWholeFileInputFormat(t *testing.T) {
+	assert.False(t, WholeFileInputFormat{}.Splittable("input"))
+	testInputFormat(t, WholeFileInputFormat{}, "foo\nbar\n", []keyValue{
+		{"input", "foo\nbar\n"},
+	})
+}
@@ -0,0 +1,235 @@
+package corral
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// IntermediateFormat identifies the encoding of intermediate (shuffle) data files.
+type IntermediateFormat string
+
+// Supported intermediate data formats
+const (
+	// BinaryFormat encodes records as length-prefixed keys and values, preceded by a versioned file header.
+	BinaryFormat IntermediateFormat = "binary"
+	// JSONFormat encodes records as JSON lines. It is slower and larger than BinaryFormat, but human-readable.
+	JSONFormat IntermediateFormat = "json"
+)
+
+// binaryMagic identifies intermediate files written in BinaryFormat
+var binaryMagic = []byte("CRLB")
+
+// binaryVersion is the version of BinaryFormat written by this version of corral
+const binaryVersion byte = 1
+
+// maxBinaryFieldSize is the largest key or value accepted when reading BinaryFormat records.
+// Larger lengths indicate a corrupt file.
+const maxBinaryFieldSize = 1 << 31
+
+// recordWriter encodes intermediate records to an underlying writer
+type recordWriter interface {
+	write(kv keyValue) error
+}
+
+// newRecordWriter returns a recordWriter for the given format. Any file header is written immediately.
+func newRecordWriter(format IntermediateFormat, w io.Writer) (recordWriter, error) {
+	switch format {
+	case BinaryFormat, "":
+		header := append(append([]byte{}, binaryMagic...), binaryVersion)
+		if _, err := w.Write(header); err != nil {
+			return nil, err
+		}
+		return &binaryRecordWriter{writer: w}, nil
+	case JSONFormat:
+		return &jsonRecordWriter{writer: w}, nil
+	}
+	return nil, fmt.Errorf("Unknown intermediate format: '%s'", format)
+}
+
+// newRecordReader returns a recordIterator over records encoded in the given format.
+// An error is returned if r does not begin with a valid header for the format.
+func newRecordReader(format IntermediateFormat, r io.Reader) (recordIterator, error) {
+	reader := bufio.NewReader(r)
+	switch format {
+	case BinaryFormat, "":
+		header := make([]byte, len(binaryMagic)+1)
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				// Empty files have no records
+				return &sliceIterator{}, nil
+			}
+			return nil, fmt.Errorf("Unable to read intermediate file header: %s", err)
+		}
+		if !bytes.Equal(header[:len(binaryMagic)], binaryMagic) {
+			return nil, fmt.Errorf("Intermediate file is not in %s format", BinaryFormat)
+		}
+		if version := header[len(binaryMagic)]; version != binaryVersion {
+			return nil, fmt.Errorf("Unsupported %s intermediate format version: %d", BinaryFormat, version)
+		}
+		return &binaryRecordReader{reader: reader}, nil
+	case JSONFormat:
+		return &jsonRecordReader{decoder: json.NewDecoder(reader)}, nil
+	}
+	return nil, fmt.Errorf("Unknown intermediate format: '%s'", format)
+}
+
+// binaryRecordWriter writes records as a uvarint key length, the key, a uvarint value length, and the value.
+type binaryRecordWriter struct {
+	writer io.Writer
+	buf    []byte
+}
+
+func (b *binaryRecordWriter) write(kv keyValue) error {
+	b.buf = b.buf[:0]
+	b.buf = appendUvarint(b.buf, uint64(len(kv.Key)))
+	b.buf = append(b.buf, kv.Key...)
+	b.buf = appendUvarint(b.buf, uint64(len(kv.Value)))
+	b.buf = append(b.buf, kv.Value...)
+	_, err := b.writer.Write(b.buf)
+	return err
+}
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+type binaryRecordReader struct {
+	reader *bufio.Reader
+}
+
+func (b *binaryRecordReader) next() (keyValue, error) {
+	key, err := b.readField()
+	if err != nil {
+		return keyValue{}, err
+	}
+	value, err := b.readField()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return keyValue{Key: key, Value: value}, err
+}
+
+// readField reads a single length-prefixed field.
+// io.EOF is only returned if no bytes of the field could be read.
+func (b *binaryRecordReader) readField() (string, error) {
+	length, err := binary.ReadUvarint(b.reader)
+	if err != nil {
+		return "", err
+	}
+	if length > maxBinaryFieldSize {
+		return "", fmt.Errorf("Corrupt intermediate file: field length %d exceeds maximum", length)
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(b.reader, field); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return string(field), nil
+}
+
+// jsonRecordWriter writes records as JSON lines
+type jsonRecordWriter struct {
+	writer io.Writer
+}
+
+func (j *jsonRecordWriter) write(kv keyValue) error {
+	data, err := json.Marshal(kv)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = j.writer.Write(data)
+	return err
+}
+
+type jsonRecordReader struct {
+	decoder *json.Decoder
+}
+
+func (j *jsonRecordReader) next() (keyValue, error) {
+	var kv keyValue
+	err := j.decoder.Decode(&kv)
+	return kv, err
+}
+
+// countingWriter wraps an io.Writer and counts the number of bytes written to it
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.written += int64(n)
+	return n, err
+}
+
+// intermediateWriter writes encoded (and optionally compressed) records to a single intermediate file
+type intermediateWriter struct {
+	file       io.WriteCloser
+	counter    *countingWriter
+	compressor io.WriteCloser
+	buffer     *bufio.Writer
+	records    recordWriter
+}
+
+func newIntermediateWriter(file io.WriteCloser, format IntermediateFormat, compression Compression) (*intermediateWriter, error) {
+	counter := &countingWriter{writer: file}
+	compressor, err := newCompressor(compression, counter)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	buffer := bufio.NewWriter(compressor)
+	records, err := newRecordWriter(format, buffer)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &intermediateWriter{
+		file:       file,
+		counter:    counter,
+		compressor: compressor,
+		buffer:     buffer,
+		records:    records,
+	}, nil
+}
+
+func (i *intermediateWriter) write(kv keyValue) error {
+	return i.records.write(kv)
+}
+
+// bytesWritten returns the number of encoded (and compressed) bytes written to the underlying file
+func (i *intermediateWriter) bytesWritten() int64 {
+	return i.counter.written
+}
+
+// close flushes any buffered records and closes the underlying file
+func (i *intermediateWriter) close() error {
+	err := i.buffer.Flush()
+	if closeErr := i.compressor.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := i.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// newIntermediateReader returns a recordIterator over the records of an intermediate file
+// that was written with the given format and compression.
+func newIntermediateReader(file io.Reader, format IntermediateFormat, compression Compression) (recordIterator, error) {
+	decompressor, err := newDecompressor(compression, file)
+	if err != nil {
+		return nil, err
+	}
+	return newRecordReader(format, decompressor)
+}
@@ -0,0 +1,78 @@
+package corral
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRoundTrip(t *testing.T, format IntermediateFormat) {
+	records := []keyValue{
+		{"key", "value"},
+		{"", ""},
+		{"tab\tkey", "multi\nline\nvalue"},
+	}
+
+	buf := new(bytes.Buffer)
+	writer, err := newRecordWriter(format, buf)
+	assert.Nil(t, err)
+	for _, kv := range records {
+		assert.Nil(t, writer.write(kv))
+	}
+
+	reader, err := newRecordReader(format, buf)
+	assert.Nil(t, err)
+	for _, kv := range records {
+		read, err := reader.next()
+		assert.Nil(t, err)
+		assert.Equal(t, kv, read)
+	}
+	_, err = reader.next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBinaryFormatRoundTrip(t *testing.T) {
+	testRoundTrip(t, BinaryFormat)
+}
+
+func TestJSONFormatRoundTrip(t *testing.T) {
+	testRoundTrip(t, JSONFormat)
+}
+
+func TestBinaryFormatRejectsJSON(t *testing.T) {
+	buf := bytes.NewBufferString(`{"key":"foo","value":"bar"}` + "\n")
+	_, err := newRecordReader(BinaryFormat, buf)
+	assert.EqualError(t, err, "Intermediate file is not in binary format")
+}
+
+func TestBinaryFormatRejectsUnknownVersion(t *testing.T) {
+	buf := bytes.NewBufferString("CRLB\x02\x03key\x05value")
+	_, err := newRecordReader(BinaryFormat, buf)
+	assert.EqualError(t, err, "Unsupported binary intermediate format version: 2")
+}
+
+func TestBinaryFormatTruncated(t *testing.T) {
+	buf := bytes.NewBufferString("CRLB\x01\x03key\x05val")
+	reader, err := newRecordReader(BinaryFormat, buf)
+	assert.Nil(t, err)
+
+	_, err = reader.next()
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestBinaryFormatEmptyFile(t *testing.T) {
+	reader, err := newRecordReader(BinaryFormat, new(bytes.Buffer))
+	assert.Nil(t, err)
+
+	_, err = reader.next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := newRecordWriter("xml", new(bytes.Buffer))
+	assert.NotNil(t, err)
+	_, err = newRecordReader("xml", new(bytes.Buffer))
+	assert.NotNil(t, err)
+}
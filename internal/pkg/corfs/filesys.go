@@ -1,6 +1,7 @@
 package corfs
 
 import (
+	"context"
 	"io"
 	"strings"
 )
@@ -18,12 +19,16 @@ const (
 // Input data is read from a file system. Intermediate and output data
 // is written to a file system.
 // This is abstracted to allow remote filesystems like S3 to be supported.
+// Readers and writers are bound to the context they are opened with, and fail
+// once that context is done.
 type FileSystem interface {
 	ListFiles(pathGlob string) ([]FileInfo, error)
 	Stat(filePath string) (FileInfo, error)
-	OpenReader(filePath string, startAt int64) (io.ReadCloser, error)
-	OpenWriter(filePath string) (io.WriteCloser, error)
+	OpenReader(ctx context.Context, filePath string, startAt int64) (io.ReadCloser, error)
+	OpenWriter(ctx context.Context, filePath string) (io.WriteCloser, error)
 	Delete(filePath string) error
+	DeleteAll(dirPath string) error
+	Rename(source, destination string) error
 	Join(elem ...string) string
 	Init() error
 }
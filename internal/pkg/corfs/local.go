@@ -1,6 +1,7 @@
 package corfs
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
@@ -11,6 +12,26 @@ import (
 // LocalFileSystem wraps "os" to provide access to the local filesystem.
 type LocalFileSystem struct{}
 
+// contextFile wraps an *os.File so that reads and writes fail once ctx is done.
+type contextFile struct {
+	*os.File
+	ctx context.Context
+}
+
+func (f *contextFile) Read(p []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+func (f *contextFile) Write(p []byte) (int, error) {
+	if err := f.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
 func walkDir(dir string) []FileInfo {
 	files := make([]FileInfo, 0)
 	filepath.Walk(dir, func(path string, f os.FileInfo, err error) error {
@@ -60,17 +81,23 @@ func (l *LocalFileSystem) ListFiles(pathGlob string) ([]FileInfo, error) {
 
 // OpenReader opens a reader to the file at filePath. The reader
 // is initially seeked to "startAt" bytes into the file.
-func (l *LocalFileSystem) OpenReader(filePath string, startAt int64) (io.ReadCloser, error) {
+func (l *LocalFileSystem) OpenReader(ctx context.Context, filePath string, startAt int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	file, err := os.OpenFile(filePath, os.O_RDONLY, 0600)
 	if err != nil {
 		return nil, err
 	}
 	_, err = file.Seek(startAt, io.SeekStart)
-	return file, err
+	return &contextFile{File: file, ctx: ctx}, err
 }
 
 // OpenWriter opens a writer to the file at filePath.
-func (l *LocalFileSystem) OpenWriter(filePath string) (io.WriteCloser, error) {
+func (l *LocalFileSystem) OpenWriter(ctx context.Context, filePath string) (io.WriteCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	dir := filepath.Dir(filePath)
 
 	// Create writer directory if necessary
@@ -79,7 +106,11 @@ func (l *LocalFileSystem) OpenWriter(filePath string) (io.WriteCloser, error) {
 		os.MkdirAll(dir, 0777)
 	}
 
-	return os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &contextFile{File: file, ctx: ctx}, nil
 }
 
 // Stat returns information about the file at filePath.
@@ -108,3 +139,17 @@ func (l *LocalFileSystem) Join(elem ...string) string {
 func (l *LocalFileSystem) Delete(filePath string) error {
 	return os.Remove(filePath)
 }
+
+// DeleteAll deletes the directory at dirPath and everything it contains.
+// It returns nil if dirPath doesn't exist.
+func (l *LocalFileSystem) DeleteAll(dirPath string) error {
+	return os.RemoveAll(dirPath)
+}
+
+// Rename moves the file at source to destination, replacing any existing file at destination.
+func (l *LocalFileSystem) Rename(source, destination string) error {
+	if err := os.MkdirAll(filepath.Dir(destination), 0777); err != nil {
+		return err
+	}
+	return os.Rename(source, destination)
+}
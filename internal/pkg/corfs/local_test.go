@@ -1,6 +1,7 @@
 package corfs
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path"
@@ -47,7 +48,7 @@ func TestLocalOpenReader(t *testing.T) {
 	path := filepath.Join(tmpdir, "tmpfile")
 
 	// Test reader that begins at beginning of file
-	reader, err := fs.OpenReader(path, 0)
+	reader, err := fs.OpenReader(context.Background(), path, 0)
 	assert.Nil(t, err)
 
 	contents, err := ioutil.ReadAll(reader)
@@ -57,7 +58,7 @@ func TestLocalOpenReader(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Test reader that begins in the middle of a file
-	reader, err = fs.OpenReader(path, 4)
+	reader, err = fs.OpenReader(context.Background(), path, 4)
 	assert.Nil(t, err)
 
 	contents, err = ioutil.ReadAll(reader)
@@ -76,7 +77,7 @@ func TestLocalOpenWriter(t *testing.T) {
 
 	path := filepath.Join(tmpdir, "tmpfile")
 
-	writer, err := fs.OpenWriter(path)
+	writer, err := fs.OpenWriter(context.Background(), path)
 	assert.Nil(t, err)
 
 	n, err := writer.Write([]byte("foo bar baz"))
@@ -115,7 +116,7 @@ func TestLocalCreateIntermediateDirectory(t *testing.T) {
 
 	fs := LocalFileSystem{}
 
-	writer, err := fs.OpenWriter(path)
+	writer, err := fs.OpenWriter(context.Background(), path)
 	assert.Nil(t, err)
 
 	_, err = writer.Write([]byte("foo"))
@@ -128,6 +129,46 @@ func TestLocalCreateIntermediateDirectory(t *testing.T) {
 	assert.True(t, stat.IsDir())
 }
 
+func TestLocalRename(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	defer os.RemoveAll(tmpdir)
+	assert.Nil(t, err)
+
+	source := path.Join(tmpdir, "tmpfile")
+	destination := path.Join(tmpdir, "additionalFolder", "renamed")
+	ioutil.WriteFile(source, []byte("foo"), 0777)
+
+	fs := LocalFileSystem{}
+	assert.Nil(t, fs.Rename(source, destination))
+
+	_, err = os.Stat(source)
+	assert.True(t, os.IsNotExist(err))
+
+	contents, err := ioutil.ReadFile(destination)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", string(contents))
+}
+
+func TestLocalDeleteAll(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	defer os.RemoveAll(tmpdir)
+	assert.Nil(t, err)
+
+	dir := path.Join(tmpdir, "dir")
+	os.MkdirAll(path.Join(dir, "subdir"), 0777)
+	ioutil.WriteFile(path.Join(dir, "tmpfile"), []byte("foo"), 0777)
+	ioutil.WriteFile(path.Join(dir, "subdir", "tmpfile"), []byte("foo"), 0777)
+
+	fs := LocalFileSystem{}
+	assert.Nil(t, fs.DeleteAll(dir))
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	// Deleting a missing directory isn't an error
+	assert.Nil(t, fs.DeleteAll(dir))
+}
+
 func TestLocalListGlob(t *testing.T) {
 	tmpdir, err := ioutil.TempDir("", "test")
 	defer os.RemoveAll(tmpdir)
@@ -146,3 +187,30 @@ func TestLocalListGlob(t *testing.T) {
 	assert.Equal(t, int64(3), files[0].Size)
 	assert.Equal(t, path, files[0].Name)
 }
+
+func TestLocalReaderWriterCancelled(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	defer os.RemoveAll(tmpdir)
+	assert.Nil(t, err)
+
+	fs := LocalFileSystem{}
+	path := filepath.Join(tmpdir, "tmpfile")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	writer, err := fs.OpenWriter(ctx, path)
+	assert.Nil(t, err)
+	reader, err := fs.OpenReader(ctx, path, 0)
+	assert.Nil(t, err)
+
+	cancel()
+
+	_, err = writer.Write([]byte("foo"))
+	assert.Equal(t, context.Canceled, err)
+	_, err = reader.Read(make([]byte, 3))
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, writer.Close())
+	assert.Nil(t, reader.Close())
+
+	_, err = fs.OpenReader(ctx, path, 0)
+	assert.Equal(t, context.Canceled, err)
+}
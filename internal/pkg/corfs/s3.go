@@ -1,6 +1,7 @@
 package corfs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -103,7 +104,7 @@ func (s *S3FileSystem) ListFiles(pathGlob string) ([]FileInfo, error) {
 
 // OpenReader opens a reader to the file at filePath. The reader
 // is initially seeked to "startAt" bytes into the file.
-func (s *S3FileSystem) OpenReader(filePath string, startAt int64) (io.ReadCloser, error) {
+func (s *S3FileSystem) OpenReader(ctx context.Context, filePath string, startAt int64) (io.ReadCloser, error) {
 	parsed, err := parseS3URI(filePath)
 	if err != nil {
 		return nil, err
@@ -115,6 +116,7 @@ func (s *S3FileSystem) OpenReader(filePath string, startAt int64) (io.ReadCloser
 	}
 
 	reader := &s3Reader{
+		ctx:       ctx,
 		client:    s.s3Client,
 		bucket:    parsed.Hostname(),
 		key:       parsed.Path,
@@ -127,13 +129,14 @@ func (s *S3FileSystem) OpenReader(filePath string, startAt int64) (io.ReadCloser
 }
 
 // OpenWriter opens a writer to the file at filePath.
-func (s *S3FileSystem) OpenWriter(filePath string) (io.WriteCloser, error) {
+func (s *S3FileSystem) OpenWriter(ctx context.Context, filePath string) (io.WriteCloser, error) {
 	parsed, err := parseS3URI(filePath)
 	if err != nil {
 		return nil, err
 	}
 
 	writer := &s3Writer{
+		ctx:            ctx,
 		client:         s.s3Client,
 		bucket:         parsed.Hostname(),
 		key:            parsed.Path,
@@ -209,6 +212,134 @@ func (s *S3FileSystem) Delete(filePath string) error {
 	return err
 }
 
+// DeleteAll deletes every object under the prefix dirPath, by listing and deleting each object.
+// It returns nil if there are no objects under dirPath.
+func (s *S3FileSystem) DeleteAll(dirPath string) error {
+	parsed, err := parseS3URI(dirPath)
+	if err != nil {
+		return err
+	}
+
+	prefix := parsed.Path
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	params := &s3.ListObjectsInput{
+		Bucket: aws.String(parsed.Hostname()),
+		Prefix: aws.String(prefix),
+	}
+
+	objectPrefix := fmt.Sprintf("%s://%s/", parsed.Scheme, parsed.Hostname())
+	var deleteErr error
+	err = s.s3Client.ListObjectsPages(params,
+		func(page *s3.ListObjectsOutput, _ bool) bool {
+			for _, object := range page.Contents {
+				_, deleteErr = s.s3Client.DeleteObject(&s3.DeleteObjectInput{
+					Bucket: aws.String(parsed.Hostname()),
+					Key:    object.Key,
+				})
+				if deleteErr != nil {
+					return false
+				}
+				s.objectCache.Remove(objectPrefix + *object.Key)
+			}
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	return deleteErr
+}
+
+// maxS3CopySize is the size (in bytes) of the largest object that can be copied in a single request
+const maxS3CopySize = 5 * 1024 * 1024 * 1024
+
+// s3CopyPartSize is the size (in bytes) of each part of a multipart copy
+const s3CopyPartSize = 1024 * 1024 * 1024
+
+// Rename moves the object at source to destination, replacing any existing object at destination.
+// S3 doesn't support renaming objects, so the object is copied and then deleted.
+func (s *S3FileSystem) Rename(source, destination string) error {
+	src, err := parseS3URI(source)
+	if err != nil {
+		return err
+	}
+	dst, err := parseS3URI(destination)
+	if err != nil {
+		return err
+	}
+
+	head, err := s.s3Client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(src.Hostname()),
+		Key:    aws.String(src.Path),
+	})
+	if err != nil {
+		return err
+	}
+
+	copySource := (&url.URL{Path: src.Hostname() + "/" + src.Path}).EscapedPath()
+	if *head.ContentLength <= maxS3CopySize {
+		_, err = s.s3Client.CopyObject(&s3.CopyObjectInput{
+			Bucket:     aws.String(dst.Hostname()),
+			Key:        aws.String(dst.Path),
+			CopySource: aws.String(copySource),
+		})
+	} else {
+		err = s.multipartCopy(copySource, dst, *head.ContentLength)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.objectCache.Remove(source)
+	s.objectCache.Remove(destination)
+	return s.Delete(source)
+}
+
+// multipartCopy copies an object that is too large to copy in a single request
+func (s *S3FileSystem) multipartCopy(copySource string, dst *url.URL, size int64) error {
+	upload, err := s.s3Client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket: aws.String(dst.Hostname()),
+		Key:    aws.String(dst.Path),
+	})
+	if err != nil {
+		return err
+	}
+
+	parts := make([]*s3.CompletedPart, 0)
+	for offset, partNumber := int64(0), int64(1); offset < size; offset, partNumber = offset+s3CopyPartSize, partNumber+1 {
+		end := min64(offset+s3CopyPartSize, size) - 1
+		part, err := s.s3Client.UploadPartCopy(&s3.UploadPartCopyInput{
+			Bucket:          aws.String(dst.Hostname()),
+			Key:             aws.String(dst.Path),
+			CopySource:      aws.String(copySource),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, end)),
+			PartNumber:      aws.Int64(partNumber),
+			UploadId:        upload.UploadId,
+		})
+		if err != nil {
+			s.s3Client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(dst.Hostname()),
+				Key:      aws.String(dst.Path),
+				UploadId: upload.UploadId,
+			})
+			return err
+		}
+		parts = append(parts, &s3.CompletedPart{
+			ETag:       part.CopyPartResult.ETag,
+			PartNumber: aws.Int64(partNumber),
+		})
+	}
+
+	_, err = s.s3Client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(dst.Hostname()),
+		Key:             aws.String(dst.Path),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+	})
+	return err
+}
+
 // Join joins file path elements
 func (s *S3FileSystem) Join(elem ...string) string {
 	stripped := make([]string, len(elem))
@@ -1,6 +1,7 @@
 package corfs
 
 import (
+	"context"
 	"fmt"
 	"io"
 
@@ -10,6 +11,7 @@ import (
 )
 
 type s3Writer struct {
+	ctx             context.Context
 	client          *s3.S3
 	bucket          string
 	key             string
@@ -24,7 +26,7 @@ func (s *s3Writer) Init() error {
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(s.key),
 	}
-	result, err := s.client.CreateMultipartUpload(params)
+	result, err := s.client.CreateMultipartUploadWithContext(s.ctx, params)
 
 	if result != nil {
 		s.uploadID = *result.UploadId
@@ -43,7 +45,7 @@ func (s *s3Writer) uploadChunk() error {
 		Body:       s.buf,
 		PartNumber: aws.Int64(partNumber),
 	}
-	result, err := s.client.UploadPart(uploadParams)
+	result, err := s.client.UploadPartWithContext(s.ctx, uploadParams)
 	if result != nil {
 		s.complatedParts = append(s.complatedParts, &s3.CompletedPart{
 			ETag:       result.ETag,
@@ -68,6 +70,12 @@ func (s *s3Writer) Write(p []byte) (n int, err error) {
 func (s *s3Writer) Close() error {
 	err := s.uploadChunk()
 
+	// Abandon the upload if the writer's context was cancelled, so that no partial object is created
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		s.abort()
+		return ctxErr
+	}
+
 	completeParams := &s3.CompleteMultipartUploadInput{
 		Bucket:   aws.String(s.bucket),
 		Key:      aws.String(s.key),
@@ -77,12 +85,24 @@ func (s *s3Writer) Close() error {
 		},
 	}
 
-	_, err = s.client.CompleteMultipartUpload(completeParams)
+	_, err = s.client.CompleteMultipartUploadWithContext(s.ctx, completeParams)
+
+	return err
+}
 
+// abort cancels the multipart upload, discarding any uploaded parts
+func (s *s3Writer) abort() error {
+	abortParams := &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(s.key),
+		UploadId: aws.String(s.uploadID),
+	}
+	_, err := s.client.AbortMultipartUpload(abortParams)
 	return err
 }
 
 type s3Reader struct {
+	ctx       context.Context
 	client    *s3.S3
 	bucket    string
 	key       string
@@ -100,9 +120,12 @@ func (s *s3Reader) loadNextChunk() error {
 		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", s.offset, s.offset+size-1)),
 	}
 	s.offset += size
-	output, err := s.client.GetObject(params)
+	output, err := s.client.GetObjectWithContext(s.ctx, params)
+	if err != nil {
+		return err
+	}
 	s.chunk = output.Body
-	return err
+	return nil
 }
 
 func (s *s3Reader) Read(b []byte) (n int, err error) {
@@ -115,5 +138,8 @@ func (s *s3Reader) Read(b []byte) (n int, err error) {
 }
 
 func (s *s3Reader) Close() error {
+	if s.chunk == nil {
+		return nil
+	}
 	return s.chunk.Close()
 }
@@ -1,6 +1,7 @@
 package corfs
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -44,7 +45,7 @@ func TestS3ReaderWriter(t *testing.T) {
 	path := bucket + "/testobj"
 
 	// Test writer
-	writer, err := backend.OpenWriter(path)
+	writer, err := backend.OpenWriter(context.Background(), path)
 	assert.Nil(t, err)
 
 	_, err = writer.Write([]byte("foo bar baz"))
@@ -54,7 +55,7 @@ func TestS3ReaderWriter(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Test reader starting at beginning of file
-	reader, err := backend.OpenReader(path, 0)
+	reader, err := backend.OpenReader(context.Background(), path, 0)
 	assert.Nil(t, err)
 
 	contents, err := ioutil.ReadAll(reader)
@@ -65,6 +66,56 @@ func TestS3ReaderWriter(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestS3Rename(t *testing.T) {
+	bucket, backend := getS3TestBackend(t)
+	defer cleanup(backend, t)
+
+	source := bucket + "/testobj"
+	destination := bucket + "/folder/renamed obj"
+
+	writer, err := backend.OpenWriter(context.Background(), source)
+	assert.Nil(t, err)
+	_, err = writer.Write([]byte("foo bar baz"))
+	assert.Nil(t, err)
+	assert.Nil(t, writer.Close())
+
+	err = backend.Rename(source, destination)
+	assert.Nil(t, err)
+
+	_, err = backend.Stat(source)
+	assert.NotNil(t, err)
+
+	reader, err := backend.OpenReader(context.Background(), destination, 0)
+	assert.Nil(t, err)
+	contents, err := ioutil.ReadAll(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo bar baz", string(contents))
+	assert.Nil(t, reader.Close())
+}
+
+func TestS3DeleteAll(t *testing.T) {
+	bucket, backend := getS3TestBackend(t)
+	defer cleanup(backend, t)
+
+	for _, name := range []string{"/dir/testobj", "/dir/subdir/testobj", "/dir2/testobj"} {
+		writer, err := backend.OpenWriter(context.Background(), bucket+name)
+		assert.Nil(t, err)
+		assert.Nil(t, writer.Close())
+	}
+
+	err := backend.DeleteAll(bucket + "/dir")
+	assert.Nil(t, err)
+
+	_, err = backend.Stat(bucket + "/dir/testobj")
+	assert.NotNil(t, err)
+	_, err = backend.Stat(bucket + "/dir/subdir/testobj")
+	assert.NotNil(t, err)
+	_, err = backend.Stat(bucket + "/dir2/testobj")
+	assert.Nil(t, err)
+
+	assert.Nil(t, backend.DeleteAll(bucket+"/dir2"))
+}
+
 func TestS3ReaderWriterWithOffset(t *testing.T) {
 	bucket, backend := getS3TestBackend(t)
 	defer cleanup(backend, t)
@@ -72,7 +123,7 @@ func TestS3ReaderWriterWithOffset(t *testing.T) {
 	path := bucket + "/testobj"
 
 	// Test writer
-	writer, err := backend.OpenWriter(path)
+	writer, err := backend.OpenWriter(context.Background(), path)
 	assert.Nil(t, err)
 
 	_, err = writer.Write([]byte("foo bar baz"))
@@ -82,7 +133,7 @@ func TestS3ReaderWriterWithOffset(t *testing.T) {
 	assert.Nil(t, err)
 
 	// Test reader starting in middle of file
-	reader, err := backend.OpenReader(path, 4)
+	reader, err := backend.OpenReader(context.Background(), path, 4)
 	assert.Nil(t, err)
 
 	contents, err := ioutil.ReadAll(reader)
@@ -99,7 +150,7 @@ func TestS3ListFiles(t *testing.T) {
 
 	for i := 0; i < 5; i++ {
 		fName := fmt.Sprintf("file%d", i)
-		writer, err := backend.OpenWriter(bucket + "/" + fName)
+		writer, err := backend.OpenWriter(context.Background(), bucket+"/"+fName)
 		assert.Nil(t, err)
 
 		_, err = writer.Write([]byte(fName))
@@ -126,7 +177,7 @@ func TestS3ListGlob(t *testing.T) {
 
 	for i := 0; i < 3; i++ {
 		fName := fmt.Sprintf("foo/file%d", i)
-		writer, err := backend.OpenWriter(bucket + "/" + fName)
+		writer, err := backend.OpenWriter(context.Background(), bucket+"/"+fName)
 		assert.Nil(t, err)
 
 		_, err = writer.Write([]byte(fName))
@@ -153,7 +204,7 @@ func TestS3Stat(t *testing.T) {
 
 	path := bucket + "/testobj"
 
-	writer, err := backend.OpenWriter(path)
+	writer, err := backend.OpenWriter(context.Background(), path)
 	assert.Nil(t, err)
 
 	_, err = writer.Write([]byte("foo bar baz"))
@@ -185,7 +236,7 @@ func TestS3ReaderChunk(t *testing.T) {
 	path := bucket + "/testobj"
 
 	// Test writer
-	writer, err := backend.OpenWriter(path)
+	writer, err := backend.OpenWriter(context.Background(), path)
 	assert.Nil(t, err)
 
 	_, err = writer.Write([]byte("foo bar baz"))
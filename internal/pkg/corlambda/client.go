@@ -3,6 +3,7 @@ package corlambda
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -24,10 +25,6 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// MaxLambdaRetries is the number of times to try invoking a function
-// before giving up and returning an error
-const MaxLambdaRetries = 3
-
 // LambdaClient wraps the AWS Lambda API and provides functions for
 // deploying and invoking lambda functions
 type LambdaClient struct {
@@ -233,14 +230,19 @@ type invokeError struct {
 	StackTrace []lambdaMessages.InvokeResponse_Error_StackFrame `json:"stackTrace"`
 }
 
-func (l *LambdaClient) tryInvoke(functionName string, payload []byte) ([]byte, error) {
+// Invoke invokes the given Lambda function with the given payload.
+// Failed invocations aren't retried: the driver retries failed tasks according to its
+// RetryPolicy. (Throttled requests are still retried by the AWS SDK.)
+func (l *LambdaClient) Invoke(ctx context.Context, functionName string, payload []byte) ([]byte, error) {
 	invokeInput := &lambda.InvokeInput{
 		FunctionName: aws.String(functionName),
 		Payload:      payload,
 	}
 
-	output, err := l.Client.Invoke(invokeInput)
-	if err != nil {
+	output, err := l.Client.InvokeWithContext(ctx, invokeInput)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return nil, ctxErr
+	} else if err != nil {
 		return nil, err
 	} else if output.FunctionError != nil {
 		var errPayload invokeError
@@ -262,15 +264,3 @@ func (l *LambdaClient) tryInvoke(functionName string, payload []byte) ([]byte, e
 	}
 	return output.Payload, err
 }
-
-// Invoke invokes the given Lambda function with the given payload.
-func (l *LambdaClient) Invoke(functionName string, payload []byte) (outputPayload []byte, err error) {
-	for try := 0; try < MaxLambdaRetries; try++ {
-		outputPayload, err = l.tryInvoke(functionName, payload)
-		if err == nil {
-			break
-		}
-		log.Warnf("Function invocation failed. (Attempt %d of %d)", try+1, MaxLambdaRetries)
-	}
-	return outputPayload, err
-}
@@ -1,6 +1,7 @@
 package corlambda
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"testing"
@@ -8,6 +9,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/stretchr/testify/assert"
 )
@@ -15,10 +17,15 @@ import (
 type lambdaInvokerMock struct {
 	lambdaiface.LambdaAPI
 	invokeFailures int
+	invocations    int
 	outputPayload  []byte
 }
 
-func (m *lambdaInvokerMock) Invoke(*lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+func (m *lambdaInvokerMock) InvokeWithContext(ctx aws.Context, _ *lambda.InvokeInput, _ ...request.Option) (*lambda.InvokeOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.invocations++
 	if m.invokeFailures > 0 {
 		m.invokeFailures--
 		return &lambda.InvokeOutput{
@@ -83,35 +90,37 @@ func TestInvoke(t *testing.T) {
 		},
 	}
 
-	output, err := client.Invoke("function", []byte("payload"))
+	output, err := client.Invoke(context.Background(), "function", []byte("payload"))
 	assert.Nil(t, err)
 
 	assert.Equal(t, []byte("payload"), output)
 }
 
-func TestInvokeRetry(t *testing.T) {
-	client := &LambdaClient{
-		&lambdaInvokerMock{
-			invokeFailures: 2,
-			outputPayload:  []byte("payload"),
-		},
+func TestInvokeFunctionError(t *testing.T) {
+	mock := &lambdaInvokerMock{
+		invokeFailures: 1,
+		outputPayload:  []byte("payload"),
 	}
+	client := &LambdaClient{mock}
 
-	output, err := client.Invoke("function", []byte("payload"))
-	assert.Nil(t, err)
-
-	assert.Equal(t, []byte("payload"), output)
+	// Failed invocations aren't retried
+	_, err := client.Invoke(context.Background(), "function", []byte("payload"))
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, mock.invocations)
 }
 
-func TestInvokeOutOfTries(t *testing.T) {
-	client := &LambdaClient{
-		&lambdaInvokerMock{
-			invokeFailures: MaxLambdaRetries + 1,
-		},
+func TestInvokeCancelled(t *testing.T) {
+	mock := &lambdaInvokerMock{
+		invokeFailures: 1,
 	}
+	client := &LambdaClient{mock}
 
-	_, err := client.Invoke("function", []byte("payload"))
-	assert.NotNil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.Invoke(ctx, "function", []byte("payload"))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, mock.invocations)
 }
 
 func TestCreateFunction(t *testing.T) {
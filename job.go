@@ -1,52 +1,148 @@
 package corral
 
 import (
-	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"strings"
-	"sync"
 	"sync/atomic"
 
 	"github.com/bcongdon/corral/internal/pkg/corfs"
 	humanize "github.com/dustin/go-humanize"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/sync/semaphore"
 )
 
 // Job is the logical container for a MapReduce job
 type Job struct {
+	// Name identifies the job. In drivers with multiple jobs, each job writes its output
+	// to a directory with this name in the working location. Defaults to "jobN", where N
+	// is the index of the job in the driver.
+	Name string
+
+	// Inputs are input files/directories that the job reads, in addition to the output of
+	// its InputJobs. If neither Inputs nor InputJobs are set, the job reads the output of the
+	// previous job in the driver (or the driver's inputs, if it is the first job).
+	Inputs []string
+	// InputJobs are jobs whose output the job reads. A job is run once all of its InputJobs
+	// have completed, and jobs that don't depend on each other are run concurrently.
+	InputJobs []*Job
+
 	Map           Mapper
 	Reduce        Reducer
 	PartitionFunc PartitionFunc
 
+	// TotalOrder partitions map output keys by range, rather than by hash, so that every key
+	// of output part N sorts before every key of part N+1 (by SortComparator). Before the map
+	// phase, the driver samples the Mapper's output keys to choose the ranges. PartitionFunc
+	// is ignored if TotalOrder is set.
+	TotalOrder bool
+
+	// SaltHotKeys splits the values of each hot key (a key with more than a reducer's share
+	// of the map output, as estimated by sampling the Mapper's output) between SaltHotKeys
+	// reducers, rather than sending them all to one reducer. Each reducer's output for hot keys
+	// is a partial result, which is merged by passing it to Reduce again in a follow-up task.
+	// So, as with Combine, Reduce must be associative and commutative, and its output must be
+	// valid input to itself. Hot keys aren't salted if TotalOrder is set.
+	SaltHotKeys int
+
+	// SortComparator orders the keys delivered to reducers, and thus the records
+	// of each output part. Keys are sorted lexicographically by default.
+	SortComparator Comparator
+	// GroupingComparator determines which sorted keys are passed to the same call
+	// to Reduce. Adjacent keys that compare equal are grouped, and Reduce receives
+	// the first key of each group. Defaults to SortComparator.
+	GroupingComparator Comparator
+	// ValueComparator optionally orders the values delivered to Reduce. Values of a
+	// group are ordered by key first, then by ValueComparator.
+	ValueComparator Comparator
+
+	// Combine is an optional Reducer that pre-aggregates the values of each key
+	// within a mapper before they are written to intermediate files.
+	// It may be run zero or more times per key, so it must be associative and
+	// commutative, and its output must be valid input to both itself and Reduce.
+	Combine Reducer
+
+	// OutputFormat determines how the records emitted by Reduce are written to
+	// output files. Defaults to TextOutputFormat. Each job of a multi-stage driver
+	// may use a different OutputFormat.
+	OutputFormat OutputFormat
+
+	// InputFormat determines how input files are split, and how their records are
+	// read and passed to Map. Defaults to TextInputFormat.
+	InputFormat InputFormat
+
 	fileSystem       corfs.FileSystem
 	config           *config
+	number           int
+	options          []Option
+	slots            taskSlots
 	intermediateBins uint
+	splitPoints      []string
+	hotKeys          []string
+	shuffle          shuffleStats
 	outputPath       string
 
 	bytesRead    int64
 	bytesWritten int64
+	counters     jobCounters
 }
 
 // Logic for running a single map task
-func (j *Job) runMapper(mapperID uint, splits []inputSplit) error {
-	emitter := newMapperEmitter(j.intermediateBins, mapperID, j.outputPath, j.fileSystem)
-	if j.PartitionFunc != nil {
+func (j *Job) runMapper(ctx context.Context, mapperID uint, attempt int, splits []InputSplit) error {
+	// Remove output of any earlier run of this attempt
+	j.abortTask(MapPhase, mapperID, attempt)
+
+	emitter := newMapperEmitter(ctx, j.intermediateBins, mapperID, j.attemptPath(MapPhase, mapperID, attempt), j.fileSystem)
+	if j.TotalOrder {
+		emitter.partitionFunc = rangePartition(j.splitPoints, j.sortComparator())
+	} else if j.PartitionFunc != nil {
 		emitter.partitionFunc = j.PartitionFunc
 	}
+	if j.config.IntermediateFormat != "" {
+		emitter.format = j.config.IntermediateFormat
+	}
+	if j.config.IntermediateCompression != "" {
+		emitter.compression = j.config.IntermediateCompression
+	}
+	if j.Combine != nil {
+		emitter.combiner = j.Combine
+		emitter.maxBufferSize = j.config.CombineBufferSize
+	}
+	if len(j.hotKeys) > 0 && j.SaltHotKeys > 1 {
+		emitter.hotKeys = make(map[string]bool, len(j.hotKeys))
+		for _, key := range j.hotKeys {
+			emitter.hotKeys[key] = true
+		}
+		emitter.salts = j.SaltHotKeys
+	}
 
+	taskCtx := j.taskContext(ctx, MapPhase, mapperID, attempt, splits)
+	if err := setupTask(j.Map, taskCtx); err != nil {
+		emitter.close()
+		return err
+	}
 	for _, split := range splits {
-		err := j.runMapperSplit(split, &emitter)
+		err := j.runMapperSplit(ctx, split, &emitter)
 		if err != nil {
-			return err
+			emitter.close()
+			return teardownTask(j.Map, taskCtx, err)
 		}
 	}
+	if err := teardownTask(j.Map, taskCtx, nil); err != nil {
+		emitter.close()
+		return err
+	}
 
-	atomic.AddInt64(&j.bytesWritten, emitter.bytesWritten())
+	if err := emitter.close(); err != nil {
+		return err
+	}
 
-	return emitter.close()
+	atomic.AddInt64(&j.bytesWritten, emitter.bytesWritten())
+	j.shuffle.record(mapperID, emitter.stats())
+	j.counters.record(MapPhase, mapperID, attempt, emitter.counters.values())
+	return nil
 }
 
 func splitInputRecord(record string) *keyValue {
@@ -62,46 +158,58 @@ func splitInputRecord(record string) *keyValue {
 	}
 }
 
-// runMapperSplit runs the mapper on a single inputSplit
-func (j *Job) runMapperSplit(split inputSplit, emitter Emitter) error {
-	offset := split.StartOffset
-	if split.StartOffset != 0 {
-		offset--
-	}
-
-	inputSource, err := j.fileSystem.OpenReader(split.Filename, split.StartOffset)
+// runMapperSplit runs the mapper on a single InputSplit
+func (j *Job) runMapperSplit(ctx context.Context, split InputSplit, emitter Emitter) error {
+	inputSource, err := j.fileSystem.OpenReader(ctx, split.Filename, split.StartOffset)
 	if err != nil {
 		return err
 	}
+	defer inputSource.Close()
 
-	scanner := bufio.NewScanner(inputSource)
-	var bytesRead int64
-	splitter := countingSplitFunc(bufio.ScanLines, &bytesRead)
-	scanner.Split(splitter)
-
-	if split.StartOffset != 0 {
-		scanner.Scan()
+	counter := &countingReader{reader: inputSource}
+	var input io.Reader = counter
+	if split.StartOffset == 0 {
+		var compressed bool
+		input, compressed, err = decompressInput(counter)
+		if err != nil {
+			return fmt.Errorf("%s: %s", split.Filename, err)
+		}
+		// Compressed inputs are never split, so the split spans the entire decompressed file
+		if compressed {
+			split.EndOffset = math.MaxInt64 - 1
+		}
 	}
 
-	for scanner.Scan() {
-		record := scanner.Text()
-		kv := splitInputRecord(record)
-		j.Map.Map(kv.Key, kv.Value, emitter)
+	records, err := j.newRecordReader(ctx, split, input)
+	if err != nil {
+		return fmt.Errorf("%s: %s", split.Filename, err)
+	}
 
-		// Stop reading when end of inputSplit is reached
-		pos := bytesRead
-		if split.Size() > 0 && pos > split.Size() {
+	mapNext := j.mapRecordFunc(records, emitter)
+	for ctx.Err() == nil {
+		err := mapNext()
+		if err == io.EOF {
 			break
+		} else if err != nil {
+			err = newRecordError(split, records, err)
+		}
+
+		if errors.Is(err, ErrMalformedRecord) {
+			log.Debug(err)
+			emitter.Counter(MalformedRecordsCounter).Inc()
+		} else if err != nil {
+			atomic.AddInt64(&j.bytesRead, counter.read)
+			return err
 		}
 	}
 
-	atomic.AddInt64(&j.bytesRead, bytesRead)
+	atomic.AddInt64(&j.bytesRead, counter.read)
 
-	return nil
+	return ctx.Err()
 }
 
 // Logic for running a single reduce task
-func (j *Job) runReducer(binID uint) error {
+func (j *Job) runReducer(ctx context.Context, binID uint, attempt int) error {
 	// Determine the intermediate data files this reducer is responsible for
 	path := j.fileSystem.Join(j.outputPath, fmt.Sprintf("map-bin%d-*", binID))
 	files, err := j.fileSystem.ListFiles(path)
@@ -109,86 +217,93 @@ func (j *Job) runReducer(binID uint) error {
 		return err
 	}
 
-	// Open emitter for output data
-	path = j.fileSystem.Join(j.outputPath, fmt.Sprintf("output-part-%d", binID))
-	emitWriter, err := j.fileSystem.OpenWriter(path)
-	defer emitWriter.Close()
+	// Open emitter for output data, removing output of any earlier run of this attempt
+	j.abortTask(ReducePhase, binID, attempt)
+	outputName := fmt.Sprintf("output-part-%d%s", binID, j.outputFormat().Extension())
+	path = j.fileSystem.Join(j.attemptPath(ReducePhase, binID, attempt), outputName)
+	emitWriter, err := j.fileSystem.OpenWriter(ctx, path)
+	if err != nil {
+		return err
+	}
+	emitter, err := newReducerEmitter(emitWriter, j.outputFormat())
 	if err != nil {
+		emitWriter.Close()
 		return err
 	}
+	defer emitter.close()
+
+	// Divert partial results for hot keys to the hot key merge task
+	var output Emitter = emitter
+	var partial *partialEmitter
+	if len(j.hotKeys) > 0 && binID < j.intermediateBins {
+		partialPath := j.fileSystem.Join(j.attemptPath(ReducePhase, binID, attempt), partialOutputName(j.intermediateBins, binID))
+		partial = newPartialEmitter(ctx, j, emitter, partialPath)
+		output = partial
+		defer partial.closePartial()
+	}
 
-	data := make(map[string][]string, 0)
-	var bytesRead int64
+	// Sort intermediate data, spilling to disk if it doesn't fit in memory
+	sorter := newExternalSorter(ctx, j, binID)
+	defer sorter.close()
 
+	var bytesRead int64
 	for _, file := range files {
-		reader, err := j.fileSystem.OpenReader(file.Name, 0)
+		reader, err := j.fileSystem.OpenReader(ctx, file.Name, 0)
 		bytesRead += file.Size
 		if err != nil {
 			return err
 		}
 
-		// Feed intermediate data into reducers
-		decoder := json.NewDecoder(reader)
-		for decoder.More() {
-			var kv keyValue
-			if err := decoder.Decode(&kv); err != nil {
-				return err
-			}
-
-			if _, ok := data[kv.Key]; !ok {
-				data[kv.Key] = make([]string, 0)
-			}
-
-			data[kv.Key] = append(data[kv.Key], kv.Value)
+		iter, err := newIntermediateReader(reader, j.config.IntermediateFormat, j.config.IntermediateCompression)
+		if err != nil {
+			reader.Close()
+			return fmt.Errorf("%s: %s", file.Name, err)
 		}
-		reader.Close()
-
-		// Delete intermediate map data
-		if j.config.Cleanup {
-			err := j.fileSystem.Delete(file.Name)
+		for {
+			kv, err := iter.next()
+			if err == io.EOF {
+				break
+			} else if err == nil {
+				err = sorter.add(kv)
+			}
 			if err != nil {
-				log.Error(err)
+				reader.Close()
+				return err
 			}
 		}
+		reader.Close()
 	}
 
-	var waitGroup sync.WaitGroup
-	sem := semaphore.NewWeighted(10)
-
-	emitter := newReducerEmitter(emitWriter)
-	for key, values := range data {
-		sem.Acquire(context.Background(), 1)
-		waitGroup.Add(1)
-		go func(key string, values []string) {
-			defer sem.Release(1)
-
-			keyChan := make(chan string)
-			keyIter := newValueIterator(keyChan)
-
-			go func() {
-				defer waitGroup.Done()
-				j.Reduce.Reduce(key, keyIter, emitter)
-			}()
-
-			for _, value := range values {
-				// Pass current value to the appropriate key channel
-				keyChan <- value
-			}
-			close(keyChan)
-		}(key, values)
+	records, err := sorter.sorted()
+	if err != nil {
+		return err
 	}
 
-	waitGroup.Wait()
+	taskCtx := j.taskContext(ctx, ReducePhase, binID, attempt, nil)
+	if err := setupTask(j.Reduce, taskCtx); err != nil {
+		return err
+	}
+	if err := teardownTask(j.Reduce, taskCtx, j.reduceGroups(ctx, records, output)); err != nil {
+		return err
+	}
+	if partial != nil {
+		if err := partial.closePartial(); err != nil {
+			return err
+		}
+	}
+	if err := emitter.close(); err != nil {
+		return err
+	}
 
 	atomic.AddInt64(&j.bytesWritten, emitter.bytesWritten())
 	atomic.AddInt64(&j.bytesRead, bytesRead)
+	j.counters.record(ReducePhase, binID, attempt, emitter.counters.values())
 
 	return nil
 }
 
 // inputSplits calculates all input files' inputSplits.
-// inputSplits also determines and saves the number of intermediate bins that will be used during the shuffle.
-func (j *Job) inputSplits(inputs []string, maxSplitSize int64) []inputSplit {
+func (j *Job) inputSplits(ctx context.Context, inputs []string, maxSplitSize int64) []InputSplit {
 	files := make([]string, 0)
 	for _, inputPath := range inputs {
 		fileInfos, err := j.fileSystem.ListFiles(inputPath)
@@ -202,7 +317,7 @@ func (j *Job) inputSplits(inputs []string, maxSplitSize int64) []inputSplit {
 		}
 	}
 
-	splits := make([]inputSplit, 0)
+	splits := make([]InputSplit, 0)
 	var totalSize int64
 	for _, inputFileName := range files {
 		fInfo, err := j.fileSystem.Stat(inputFileName)
@@ -212,25 +327,152 @@ func (j *Job) inputSplits(inputs []string, maxSplitSize int64) []inputSplit {
 		}
 
 		totalSize += fInfo.Size
-		splits = append(splits, splitInputFile(fInfo, maxSplitSize)...)
+		if !j.inputFormat().Splittable(inputFileName) {
+			splits = append(splits, wholeFileSplit(fInfo)...)
+			continue
+		}
+		if fInfo.Size > maxSplitSize && !isCompressedInput(inputFileName) && j.isCompressedInputFile(ctx, inputFileName) {
+			splits = append(splits, wholeFileSplit(fInfo)...)
+			continue
+		}
+		fileSplits := splitInputFile(fInfo, maxSplitSize)
+		if aligner, ok := j.inputFormat().(splitAligner); ok && len(fileSplits) > 1 {
+			aligned, err := aligner.alignSplits(ctx, j.fileSystem, fileSplits)
+			if err != nil {
+				log.Warnf("Unable to align splits of input file, so it won't be split: %s (%s)", inputFileName, err)
+				aligned = wholeFileSplit(fInfo)
+			}
+			fileSplits = aligned
+		}
+		splits = append(splits, fileSplits...)
 	}
 	if len(files) > 0 {
 		log.Debugf("Average split size: %s bytes", humanize.Bytes(uint64(totalSize)/uint64(len(splits))))
 	}
 
-	j.intermediateBins = uint(float64(totalSize/j.config.ReduceBinSize) * 1.25)
-	if j.intermediateBins == 0 {
-		j.intermediateBins = 1
+	return splits
+}
+
+// inputFormat returns the job's InputFormat, or the default TextInputFormat if unset
+func (j *Job) inputFormat() InputFormat {
+	if j.InputFormat == nil {
+		return TextInputFormat{}
 	}
+	return j.InputFormat
+}
 
-	return splits
+// outputFormat returns the job's OutputFormat, or the default TextOutputFormat if unset
+func (j *Job) outputFormat() OutputFormat {
+	if j.OutputFormat == nil {
+		return TextOutputFormat{}
+	}
+	return j.OutputFormat
+}
+
+// mapRecordFunc returns a function that reads the next record from records and passes it
+// to the job's Mapper, using the most specific interface that both of them implement.
+// Errors returned by the Mapper are returned by the function.
+func (j *Job) mapRecordFunc(records RecordReader, emitter Emitter) func() error {
+	mapper := unwrapAdapter(j.Map)
+
+	if decoded, ok := records.(DecodedRecordReader); ok {
+		var mapRecord func(key string, record interface{}, emitter Emitter) error
+		if m, ok := mapper.(RecordMapperE); ok {
+			mapRecord = m.MapRecord
+		} else if m, ok := mapper.(RecordMapper); ok {
+			mapRecord = func(key string, record interface{}, emitter Emitter) error {
+				m.MapRecord(key, record, emitter)
+				return nil
+			}
+		}
+		if mapRecord != nil {
+			return func() error {
+				key, record, err := decoded.NextRecord()
+				if err != nil {
+					return err
+				}
+				return mapRecord(key, record, emitter)
+			}
+		}
+	}
+
+	if fieldsRecords, ok := records.(FieldsRecordReader); ok {
+		var mapFields func(header, fields []string, emitter Emitter) error
+		if m, ok := mapper.(FieldsMapperE); ok {
+			mapFields = m.MapFields
+		} else if m, ok := mapper.(FieldsMapper); ok {
+			mapFields = func(header, fields []string, emitter Emitter) error {
+				m.MapFields(header, fields, emitter)
+				return nil
+			}
+		}
+		if mapFields != nil {
+			return func() error {
+				header, fields, err := fieldsRecords.NextFields()
+				if err != nil {
+					return err
+				}
+				return mapFields(header, fields, emitter)
+			}
+		}
+	}
+
+	mapFunc := func(key, value string, emitter Emitter) error {
+		j.Map.Map(key, value, emitter)
+		return nil
+	}
+	if m, ok := mapper.(MapperE); ok {
+		mapFunc = m.Map
+	}
+	return func() error {
+		key, value, err := records.Next()
+		if err != nil {
+			return err
+		}
+		return mapFunc(key, value, emitter)
+	}
+}
+
+// newRecordReader returns a RecordReader over split, whose file is read by r.
+// If the job's InputFormat reads a header from the start of each file, and the split
+// doesn't begin at the start of its file, the header is read from a second reader.
+func (j *Job) newRecordReader(ctx context.Context, split InputSplit, r io.Reader) (RecordReader, error) {
+	format, ok := j.inputFormat().(headerInputFormat)
+	if !ok || !format.hasHeader() || split.StartOffset == 0 {
+		return j.inputFormat().NewRecordReader(split, r)
+	}
+
+	header, err := j.fileSystem.OpenReader(ctx, split.Filename, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer header.Close()
+	return format.newHeaderRecordReader(split, r, header)
+}
+
+// isCompressedInputFile returns true if the input file has the magic number of a supported compressed format,
+// even if it doesn't have a compressed file extension.
+func (j *Job) isCompressedInputFile(ctx context.Context, filename string) bool {
+	reader, err := j.fileSystem.OpenReader(ctx, filename, 0)
+	if err != nil {
+		log.Warnf("Unable to read input file header: %s (%s)", filename, err)
+		return false
+	}
+	defer reader.Close()
+
+	header := make([]byte, len(bzip2Magic)+1)
+	n, _ := io.ReadFull(reader, header)
+	return hasCompressedInputMagic(header[:n])
 }
 
 // NewJob creates a new job from a Mapper and Reducer.
-func NewJob(mapper Mapper, reducer Reducer) *Job {
+// Options override the configuration of the driver that runs the job, for this job only
+// (e.g. to use a different ReduceBinSize for one stage of a multi-stage driver).
+func NewJob(mapper Mapper, reducer Reducer, options ...Option) *Job {
 	return &Job{
-		Map:    mapper,
-		Reduce: reducer,
-		config: &config{},
+		Map:     mapper,
+		Reduce:  reducer,
+		config:  &config{},
+		options: options,
 	}
 }
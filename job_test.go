@@ -1,9 +1,18 @@
 package corral
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
 )
 
 func TestSplitInputRecord(t *testing.T) {
@@ -25,3 +34,115 @@ func TestSplitInputRecord(t *testing.T) {
 		assert.Equal(t, test.expectedValue, keyVal.Value)
 	}
 }
+
+// testErrorJob is a word count job whose Map, MapFields and Reduce fail on the word "bad"
+type testErrorJob struct{}
+
+var errBadWord = errors.New("bad word")
+
+func (testErrorJob) Map(key, value string, emitter Emitter) error {
+	for _, word := range strings.Fields(value) {
+		if word == "bad" {
+			return errBadWord
+		}
+		if word == "skip" {
+			return fmt.Errorf("%w: %s", ErrMalformedRecord, value)
+		}
+		if err := emitter.Emit(word, "1"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (testErrorJob) MapFields(header, fields []string, emitter Emitter) error {
+	return testErrorJob{}.Map("", strings.Join(fields, " "), emitter)
+}
+
+func (testErrorJob) Reduce(key string, values ValueIterator, emitter Emitter) error {
+	if key == "bad" {
+		return errBadWord
+	}
+	testWCJob{}.Reduce(key, values, emitter)
+	return nil
+}
+
+func newTestErrorJob(t *testing.T, input string) (*Job, InputSplit) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmpdir) })
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte(input), 0600)
+
+	job := NewJob(AdaptMapperE(testErrorJob{}), AdaptReducerE(testErrorJob{}))
+	job.fileSystem = &corfs.LocalFileSystem{}
+	job.outputPath = tmpdir
+	job.intermediateBins = 1
+	return job, InputSplit{Filename: inputPath, EndOffset: int64(len(input)) - 1}
+}
+
+func TestMapperBytesWritten(t *testing.T) {
+	job, split := newTestErrorJob(t, "foo bar baz")
+
+	assert.Nil(t, job.runMapper(context.Background(), 0, 0, []InputSplit{split}))
+	mapBytes := job.bytesWritten
+	assert.True(t, mapBytes > 0)
+
+	assert.Nil(t, job.commitTask(MapPhase, 0, 0))
+	assert.Nil(t, job.runReducer(context.Background(), 0, 0))
+	assert.True(t, job.bytesWritten > mapBytes)
+}
+
+func TestMapperError(t *testing.T) {
+	job, split := newTestErrorJob(t, "foo\nbar baz\nqux bad\n")
+
+	err := job.runMapper(context.Background(), 0, 0, []InputSplit{split})
+	assert.True(t, errors.Is(err, errBadWord))
+
+	var recordErr *RecordError
+	assert.True(t, errors.As(err, &recordErr))
+	assert.Equal(t, split.Filename, recordErr.Filename)
+	assert.Equal(t, int64(12), recordErr.Offset)
+	assert.EqualError(t, err, fmt.Sprintf("%s (offset 12): bad word", split.Filename))
+}
+
+func TestFieldsMapperError(t *testing.T) {
+	job, split := newTestErrorJob(t, "foo,bar\nbad,baz\n")
+	job.InputFormat = CSVInputFormat{}
+
+	var recordErr *RecordError
+	err := job.runMapper(context.Background(), 0, 0, []InputSplit{split})
+	assert.True(t, errors.As(err, &recordErr))
+	assert.Equal(t, int64(8), recordErr.Offset)
+	assert.True(t, errors.Is(err, errBadWord))
+}
+
+func TestMapperMalformedRecord(t *testing.T) {
+	job, split := newTestErrorJob(t, "foo\nskip\nbar\n")
+
+	err := job.runMapper(context.Background(), 0, 0, []InputSplit{split})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), job.counters.attempt(MapPhase, 0, 0)[MalformedRecordsCounter])
+}
+
+func TestReducerError(t *testing.T) {
+	job, split := newTestErrorJob(t, "foo bad")
+	job.Map = testWCJob{}
+	assert.Nil(t, job.runMapper(context.Background(), 0, 0, []InputSplit{split}))
+	assert.Nil(t, job.commitTask(MapPhase, 0, 0))
+
+	err := job.runReducer(context.Background(), 0, 0)
+	assert.True(t, errors.Is(err, errBadWord))
+	assert.EqualError(t, err, "key \"bad\": bad word")
+}
+
+func TestCombinerError(t *testing.T) {
+	job, split := newTestErrorJob(t, "foo bad")
+	job.Map = testWCJob{}
+	job.Combine = AdaptReducerE(testErrorJob{})
+	job.config.CombineBufferSize = 1024
+
+	err := job.runMapper(context.Background(), 0, 0, []InputSplit{split})
+	assert.True(t, errors.Is(err, errBadWord))
+}
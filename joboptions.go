@@ -0,0 +1,97 @@
+package corral
+
+import (
+	"context"
+
+	humanize "github.com/dustin/go-humanize"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/semaphore"
+)
+
+// configureJobs sets the effective configuration of each of the driver's jobs: the driver's
+// configuration, overridden by any options that the job was created with.
+// Inputs set by a job's options are read in addition to the job's other inputs.
+// Settings that apply to the whole driver (Timeout, Overwrite and Resume) can't be overridden.
+func (d *Driver) configureJobs() {
+	for idx, job := range d.jobs {
+		c := *d.config
+		c.Inputs = nil
+		for _, f := range job.options {
+			f(&c)
+		}
+		c.Timeout = d.config.Timeout
+		c.Overwrite = d.config.Overwrite
+		c.Resume = d.config.Resume
+
+		if c.SplitSize > c.MapBinSize {
+			log.Warnf("%s: Configured Split Size is larger than Map Bin size", d.jobName(idx))
+			c.SplitSize = c.MapBinSize
+		}
+		*job.config = c
+		job.number = idx
+
+		job.slots = taskSlots{driver: d.sem}
+		if c.MaxConcurrency < d.config.MaxConcurrency {
+			job.slots.job = semaphore.NewWeighted(int64(c.MaxConcurrency))
+		}
+	}
+}
+
+// logSettings logs the effective settings of the job at idx
+func (d *Driver) logSettings(idx int) {
+	c := d.jobs[idx].config
+	log.Infof("Job %s - Split Size: %s, Map Bin Size: %s, Reduce Bin Size: %s",
+		d.jobName(idx), humanize.Bytes(uint64(c.SplitSize)), humanize.Bytes(uint64(c.MapBinSize)), humanize.Bytes(uint64(c.ReduceBinSize)))
+	log.Infof("Job %s - Max Concurrency: %d, Cleanup: %t, Working Location: %s",
+		d.jobName(idx), c.MaxConcurrency, c.Cleanup, d.jobs[idx].outputPath)
+	if len(c.Inputs) > 0 {
+		log.Infof("Job %s - Additional Inputs: %v", d.jobName(idx), c.Inputs)
+	}
+}
+
+// taskSlots limits the number of tasks that run at once. Each running task holds a slot
+// of the driver, which is shared by all of its jobs, and of its job, if the job's
+// MaxConcurrency is lower than the driver's.
+type taskSlots struct {
+	driver *semaphore.Weighted
+	job    *semaphore.Weighted
+}
+
+// acquire blocks until a slot is available, or ctx is done
+func (t taskSlots) acquire(ctx context.Context) error {
+	// The job's slot is acquired first, so that tasks waiting on their job don't hold driver slots
+	if t.job != nil {
+		if err := t.job.Acquire(ctx, 1); err != nil {
+			return err
+		}
+	}
+	if err := t.driver.Acquire(ctx, 1); err != nil {
+		if t.job != nil {
+			t.job.Release(1)
+		}
+		return err
+	}
+	return nil
+}
+
+// tryAcquire acquires a slot without blocking, and reports whether it succeeded
+func (t taskSlots) tryAcquire() bool {
+	if t.job != nil && !t.job.TryAcquire(1) {
+		return false
+	}
+	if !t.driver.TryAcquire(1) {
+		if t.job != nil {
+			t.job.Release(1)
+		}
+		return false
+	}
+	return true
+}
+
+// release releases a slot acquired by acquire or tryAcquire
+func (t taskSlots) release() {
+	t.driver.Release(1)
+	if t.job != nil {
+		t.job.Release(1)
+	}
+}
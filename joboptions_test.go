@@ -0,0 +1,157 @@
+package corral
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+func TestConfigureJobs(t *testing.T) {
+	job0 := NewJob(testWCJob{}, testWCJob{})
+	job1 := NewJob(testWCJob{}, testWCJob{},
+		WithReduceBinSize(1024),
+		WithInputs("side_input"),
+		WithTimeout(time.Second),
+		WithResume(),
+	)
+	driver := NewMultiStageDriver([]*Job{job0, job1},
+		WithInputs("input"),
+		WithReduceBinSize(4096),
+	)
+	driver.configureJobs()
+
+	assert.Equal(t, int64(4096), job0.config.ReduceBinSize)
+	assert.Empty(t, job0.config.Inputs)
+
+	assert.Equal(t, int64(1024), job1.config.ReduceBinSize)
+	assert.Equal(t, []string{"side_input"}, job1.config.Inputs)
+	assert.Equal(t, driver.config.Timeout, job1.config.Timeout)
+	assert.False(t, job1.config.Resume)
+
+	// The driver's configuration is unchanged
+	assert.Equal(t, int64(4096), driver.config.ReduceBinSize)
+	assert.Equal(t, []string{"input"}, driver.config.Inputs)
+}
+
+func TestJobInputsWithAdditionalInputs(t *testing.T) {
+	job0 := NewJob(testWCJob{}, testWCJob{}, WithInputs("side_input"))
+	job1 := NewJob(testWCJob{}, testWCJob{}, WithInputs("other_input"))
+	driver := NewMultiStageDriver([]*Job{job0, job1},
+		WithInputs("input"),
+		WithWorkingLocation("out"),
+	)
+	driver.configureJobs()
+	job0.fileSystem = &corfs.LocalFileSystem{}
+	job0.outputPath = driver.jobWorkingLocation(job0.fileSystem, 0)
+
+	assert.Equal(t, []string{"input", "side_input"}, driver.jobInputs(0, nil))
+	assert.Equal(t, []string{filepath.Join("out", "job0", "output-*"), "other_input"}, driver.jobInputs(1, []int{0}))
+	assert.Equal(t, []string{"input"}, driver.config.Inputs)
+}
+
+func TestJobWorkingLocation(t *testing.T) {
+	job0 := NewJob(testWCJob{}, testWCJob{})
+	job1 := NewJob(testWCJob{}, testWCJob{}, WithWorkingLocation("elsewhere"))
+	driver := NewMultiStageDriver([]*Job{job0, job1}, WithWorkingLocation("out"))
+	driver.configureJobs()
+
+	fs := &corfs.LocalFileSystem{}
+	assert.Equal(t, filepath.Join("out", "job0"), driver.jobWorkingLocation(fs, 0))
+	assert.Equal(t, "elsewhere", driver.jobWorkingLocation(fs, 1))
+}
+
+func TestTaskSlots(t *testing.T) {
+	slots := taskSlots{driver: semaphore.NewWeighted(2), job: semaphore.NewWeighted(1)}
+
+	assert.Nil(t, slots.acquire(context.Background()))
+	assert.False(t, slots.tryAcquire())
+
+	slots.release()
+	assert.True(t, slots.tryAcquire())
+	slots.release()
+
+	// Failing to acquire a driver slot releases the job's slot
+	assert.True(t, slots.driver.TryAcquire(2))
+	assert.False(t, slots.tryAcquire())
+	assert.True(t, slots.job.TryAcquire(1))
+}
+
+// concurrencyExecutor records the greatest number of map tasks of each job that ran at once
+type concurrencyExecutor struct {
+	localExecutor
+	mut     sync.Mutex
+	running map[int]int
+	max     map[int]int
+}
+
+func (c *concurrencyExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	c.mut.Lock()
+	c.running[jobNumber]++
+	if c.running[jobNumber] > c.max[jobNumber] {
+		c.max[jobNumber] = c.running[jobNumber]
+	}
+	c.mut.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	err := c.localExecutor.RunMapper(ctx, job, jobNumber, binID, attempt, inputSplits)
+
+	c.mut.Lock()
+	c.running[jobNumber]--
+	c.mut.Unlock()
+	return err
+}
+
+func TestRunJobOptions(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("foo bar baz qux"), 0700)
+	sidePath := filepath.Join(tmpdir, "side_input")
+	ioutil.WriteFile(sidePath, []byte("side"), 0700)
+
+	job0 := NewJob(testPrefixJob{"a_"}, testPrefixJob{"a_"})
+	// Each word of job0's output is read by a separate map task
+	job1 := NewJob(testWCJob{}, testWCJob{},
+		WithSplitSize(6),
+		WithMapBinSize(6),
+		WithMaxConcurrency(1),
+		WithInputs(sidePath),
+		WithWorkingLocation(filepath.Join(tmpdir, "final")),
+	)
+	driver := NewMultiStageDriver([]*Job{job0, job1},
+		WithInputs(inputPath),
+		WithWorkingLocation(filepath.Join(tmpdir, "out")),
+		WithMaxConcurrency(4),
+	)
+	executor := &concurrencyExecutor{running: map[int]int{}, max: map[int]int{}}
+	driver.executor = executor
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "final", "output-part-0"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []keyValue{
+		{"a_foo", "1"},
+		{"a_bar", "1"},
+		{"a_baz", "1"},
+		{"a_qux", "1"},
+		{"side", "1"},
+	}, testOutputToKeyValues(string(output)))
+
+	_, err = os.Stat(filepath.Join(tmpdir, "out", "job0", "_SUCCESS"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, executor.max[1])
+}
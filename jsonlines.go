@@ -0,0 +1,180 @@
+package corral
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxJSONLineSize is the maximum size (in bytes) of a single line of JSON Lines input
+const maxJSONLineSize = 16 * 1024 * 1024
+
+// JSONLinesInputFormat reads newline-delimited JSON (JSON Lines) records. Each line is
+// validated, and malformed lines are skipped and counted rather than passed to the mapper.
+// Valid lines are passed to Map as a value (the line's JSON text), or, if the job's Mapper
+// implements RecordMapper, are decoded and passed to MapRecord.
+type JSONLinesInputFormat struct {
+	// KeyField optionally names the field of each record to use as its key. Nested fields
+	// can be selected with a dot-separated path (e.g. "user.id"). String fields are used as-is;
+	// other values are used in their JSON encoding. Records without the field have an empty key.
+	KeyField string
+	// NewRecord optionally returns a pointer to a new value to decode each record into,
+	// such as a pointer to a struct. By default, records are decoded into an interface{}
+	// (numbers are decoded as json.Number).
+	NewRecord func() interface{}
+	// DisallowUnknownFields causes records with fields that don't exist in the value returned by
+	// NewRecord to be treated as malformed.
+	DisallowUnknownFields bool
+	// FailOnMalformed causes malformed lines to fail the map task, rather than being skipped.
+	FailOnMalformed bool
+}
+
+// Splittable returns true for all files
+func (JSONLinesInputFormat) Splittable(filename string) bool {
+	return true
+}
+
+// NewRecordReader returns a RecordReader over the JSON records of split.
+// The returned RecordReader also implements DecodedRecordReader.
+func (f JSONLinesInputFormat) NewRecordReader(split InputSplit, r io.Reader) (RecordReader, error) {
+	lines := newDelimitedRecordReader(split, r, bufio.ScanLines, 1, func(offset int64, record string) (string, string) {
+		return "", record
+	})
+	lines.scanner.Buffer(nil, maxJSONLineSize)
+
+	var keyPath []string
+	if f.KeyField != "" {
+		keyPath = strings.Split(f.KeyField, ".")
+	}
+
+	return &jsonLinesRecordReader{
+		lines:   lines,
+		format:  f,
+		keyPath: keyPath,
+	}, nil
+}
+
+// jsonLinesRecordReader reads and validates the JSON records of a split
+type jsonLinesRecordReader struct {
+	lines   *delimitedRecordReader
+	format  JSONLinesInputFormat
+	keyPath []string
+}
+
+// malformed returns an error describing a malformed line
+func (j *jsonLinesRecordReader) malformed(err error) error {
+	if j.format.FailOnMalformed {
+		return fmt.Errorf("Malformed JSON record: %s", err)
+	}
+	return fmt.Errorf("%w: %s", ErrMalformedRecord, err)
+}
+
+// nextLine returns the next non-blank line, and the value of its key field
+func (j *jsonLinesRecordReader) nextLine() ([]byte, string, error) {
+	for {
+		_, record, err := j.lines.Next()
+		if err != nil {
+			return nil, "", err
+		}
+
+		line := bytes.TrimSpace([]byte(record))
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			return nil, "", j.malformed(fmt.Errorf("invalid JSON: %q", truncate(record, 64)))
+		}
+
+		key, err := jsonField(line, j.keyPath)
+		if err != nil {
+			return nil, "", j.malformed(err)
+		}
+		return line, key, nil
+	}
+}
+
+// Next returns the key and JSON text of the next record
+func (j *jsonLinesRecordReader) Next() (string, string, error) {
+	line, key, err := j.nextLine()
+	if err != nil {
+		return "", "", err
+	}
+	return key, string(line), nil
+}
+
+// NextRecord returns the key and decoded value of the next record
+func (j *jsonLinesRecordReader) NextRecord() (string, interface{}, error) {
+	line, key, err := j.nextLine()
+	if err != nil {
+		return "", nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	if j.format.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if j.format.NewRecord == nil {
+		var record interface{}
+		decoder.UseNumber()
+		if err := decoder.Decode(&record); err != nil {
+			return "", nil, j.malformed(err)
+		}
+		return key, record, nil
+	}
+
+	record := j.format.NewRecord()
+	if err := decoder.Decode(record); err != nil {
+		return "", nil, j.malformed(err)
+	}
+	return key, record, nil
+}
+
+func (j *jsonLinesRecordReader) recordOffset() int64 {
+	return j.lines.recordOffset()
+}
+
+// jsonField returns the value of the field at path within the JSON object data.
+// String values are unquoted, and other values are returned as JSON. Missing fields
+// (and null values) are returned as an empty string.
+func jsonField(data []byte, path []string) (string, error) {
+	if len(path) == 0 {
+		return "", nil
+	}
+
+	value := json.RawMessage(data)
+	for _, field := range path {
+		if bytes.Equal(value, []byte("null")) {
+			return "", nil
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err != nil {
+			return "", fmt.Errorf("unable to read key field '%s': %s", strings.Join(path, "."), err)
+		}
+		var ok bool
+		if value, ok = object[field]; !ok {
+			return "", nil
+		}
+	}
+
+	if bytes.Equal(value, []byte("null")) {
+		return "", nil
+	}
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str, nil
+	}
+	return string(value), nil
+}
+
+// truncate shortens s to at most n bytes, for use in error messages
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
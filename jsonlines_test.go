@@ -0,0 +1,78 @@
+package corral
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLinesInputFormat(t *testing.T) {
+	format := JSONLinesInputFormat{KeyField: "user.id"}
+	data := "{\"user\":{\"id\":\"a\"},\"n\":1}\n\n  {\"user\":{\"id\":2}}  \n{\"user\":null}\n{\"other\":true}\n[1,2]\n"
+	testInputFormat(t, format, data, []keyValue{
+		{"a", "{\"user\":{\"id\":\"a\"},\"n\":1}"},
+		{"2", "{\"user\":{\"id\":2}}"},
+		{"", "{\"user\":null}"},
+		{"", "{\"other\":true}"},
+	})
+}
+
+func readJSONLines(t *testing.T, format JSONLinesInputFormat, data string) (records []interface{}, malformed int) {
+	reader, err := format.NewRecordReader(InputSplit{EndOffset: int64(len(data)) - 1}, bytes.NewReader([]byte(data)))
+	assert.Nil(t, err)
+
+	for {
+		_, record, err := reader.(DecodedRecordReader).NextRecord()
+		if err == io.EOF {
+			return records, malformed
+		} else if errors.Is(err, ErrMalformedRecord) {
+			malformed++
+			continue
+		}
+		assert.Nil(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestJSONLinesInputFormatMalformed(t *testing.T) {
+	data := "{\"a\":1}\n{\"a\":\n{\"a\":2}\nnot json\n"
+	records, malformed := readJSONLines(t, JSONLinesInputFormat{}, data)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"a": json.Number("1")},
+		map[string]interface{}{"a": json.Number("2")},
+	}, records)
+	assert.Equal(t, 2, malformed)
+
+	reader, err := JSONLinesInputFormat{FailOnMalformed: true}.NewRecordReader(InputSplit{EndOffset: int64(len(data)) - 1}, bytes.NewReader([]byte(data)))
+	assert.Nil(t, err)
+	_, _, err = reader.Next()
+	assert.Nil(t, err)
+	_, _, err = reader.Next()
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrMalformedRecord))
+}
+
+type testJSONRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestJSONLinesInputFormatTypedRecords(t *testing.T) {
+	format := JSONLinesInputFormat{
+		NewRecord: func() interface{} {
+			return &testJSONRecord{}
+		},
+		DisallowUnknownFields: true,
+	}
+	data := "{\"name\":\"foo\",\"count\":1}\n{\"name\":\"bar\",\"count\":\"two\"}\n{\"name\":\"baz\",\"extra\":1}\n{\"name\":\"qux\"}\n"
+	records, malformed := readJSONLines(t, format, data)
+	assert.Equal(t, []interface{}{
+		&testJSONRecord{Name: "foo", Count: 1},
+		&testJSONRecord{Name: "qux"},
+	}, records)
+	assert.Equal(t, 2, malformed)
+}
@@ -35,10 +35,16 @@ func runningInLambda() bool {
 	return true
 }
 
-func prepareResult(job *Job) string {
+func prepareResult(job *Job, task task) string {
 	result := taskResult{
 		BytesRead:    int(job.bytesRead),
 		BytesWritten: int(job.bytesWritten),
+		Counters:     job.counters.attempt(task.Phase, task.BinID, task.Attempt),
+	}
+	if task.Phase == MapPhase {
+		if stats, ok := job.shuffle.get(task.BinID); ok {
+			result.Shuffle = &stats
+		}
 	}
 
 	payload, _ := json.Marshal(result)
@@ -53,20 +59,30 @@ func handleRequest(ctx context.Context, task task) (string, error) {
 	fs := corfs.InitFilesystem(task.FileSystemType)
 	currentJob := lambdaDriver.jobs[task.JobNumber]
 	currentJob.fileSystem = fs
+	currentJob.number = task.JobNumber
 	currentJob.intermediateBins = task.IntermediateBins
+	currentJob.splitPoints = task.SplitPoints
+	currentJob.hotKeys = task.HotKeys
 	currentJob.outputPath = task.WorkingLocation
-	currentJob.config.Cleanup = task.Cleanup
+	currentJob.config.CombineBufferSize = task.CombineBufferSize
+	currentJob.config.SortBufferSize = task.SortBufferSize
+	currentJob.config.SpillLocation = task.SpillLocation
+	currentJob.config.IntermediateFormat = task.IntermediateFormat
+	currentJob.config.IntermediateCompression = task.IntermediateCompression
+	currentJob.config.Params = task.Params
 
 	// Need to reset job counters in case this is a reused lambda
 	currentJob.bytesRead = 0
 	currentJob.bytesWritten = 0
+	currentJob.counters.reset()
+	currentJob.shuffle.reset()
 
 	if task.Phase == MapPhase {
-		err := currentJob.runMapper(task.BinID, task.Splits)
-		return prepareResult(currentJob), err
+		err := currentJob.runMapper(ctx, task.BinID, task.Attempt, task.Splits)
+		return prepareResult(currentJob, task), err
 	} else if task.Phase == ReducePhase {
-		err := currentJob.runReducer(task.BinID)
-		return prepareResult(currentJob), err
+		err := currentJob.runReducer(ctx, task.BinID, task.Attempt)
+		return prepareResult(currentJob, task), err
 	}
 	return "", fmt.Errorf("Unknown phase: %d", task.Phase)
 }
@@ -97,49 +113,72 @@ func loadTaskResult(payload []byte) taskResult {
 	return result
 }
 
-func (l *lambdaExecutor) RunMapper(job *Job, jobNumber int, binID uint, inputSplits []inputSplit) error {
+func (l *lambdaExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
 	mapTask := task{
-		JobNumber:        jobNumber,
-		Phase:            MapPhase,
-		BinID:            binID,
-		Splits:           inputSplits,
-		IntermediateBins: job.intermediateBins,
-		FileSystemType:   corfs.S3,
-		WorkingLocation:  job.outputPath,
+		JobNumber:               jobNumber,
+		Phase:                   MapPhase,
+		BinID:                   binID,
+		Attempt:                 attempt,
+		Splits:                  inputSplits,
+		IntermediateBins:        job.intermediateBins,
+		SplitPoints:             job.splitPoints,
+		HotKeys:                 job.hotKeys,
+		FileSystemType:          corfs.S3,
+		WorkingLocation:         job.outputPath,
+		CombineBufferSize:       job.config.CombineBufferSize,
+		IntermediateFormat:      job.config.IntermediateFormat,
+		IntermediateCompression: job.config.IntermediateCompression,
+		Params:                  job.config.Params,
 	}
 	payload, err := json.Marshal(mapTask)
 	if err != nil {
 		return err
 	}
 
-	resultPayload, err := l.Invoke(l.functionName, payload)
+	resultPayload, err := l.Invoke(ctx, l.functionName, payload)
 	taskResult := loadTaskResult(resultPayload)
 
 	atomic.AddInt64(&job.bytesRead, int64(taskResult.BytesRead))
 	atomic.AddInt64(&job.bytesWritten, int64(taskResult.BytesWritten))
+	if err == nil {
+		job.counters.record(MapPhase, binID, attempt, taskResult.Counters)
+		if taskResult.Shuffle != nil {
+			job.shuffle.record(binID, *taskResult.Shuffle)
+		}
+	}
 
 	return err
 }
 
-func (l *lambdaExecutor) RunReducer(job *Job, jobNumber int, binID uint) error {
+func (l *lambdaExecutor) RunReducer(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int) error {
 	mapTask := task{
-		JobNumber:       jobNumber,
-		Phase:           ReducePhase,
-		BinID:           binID,
-		FileSystemType:  corfs.S3,
-		WorkingLocation: job.outputPath,
-		Cleanup:         job.config.Cleanup,
+		JobNumber:               jobNumber,
+		Phase:                   ReducePhase,
+		BinID:                   binID,
+		Attempt:                 attempt,
+		IntermediateBins:        job.intermediateBins,
+		HotKeys:                 job.hotKeys,
+		FileSystemType:          corfs.S3,
+		WorkingLocation:         job.outputPath,
+		SortBufferSize:          job.config.SortBufferSize,
+		SpillLocation:           job.config.SpillLocation,
+		IntermediateFormat:      job.config.IntermediateFormat,
+		IntermediateCompression: job.config.IntermediateCompression,
+		Params:                  job.config.Params,
 	}
 	payload, err := json.Marshal(mapTask)
 	if err != nil {
 		return err
 	}
 
-	resultPayload, err := l.Invoke(l.functionName, payload)
+	resultPayload, err := l.Invoke(ctx, l.functionName, payload)
 	taskResult := loadTaskResult(resultPayload)
 
 	atomic.AddInt64(&job.bytesRead, int64(taskResult.BytesRead))
 	atomic.AddInt64(&job.bytesWritten, int64(taskResult.BytesWritten))
+	if err == nil {
+		job.counters.record(ReducePhase, binID, attempt, taskResult.Counters)
+	}
 
 	return err
 }
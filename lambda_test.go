@@ -3,11 +3,18 @@ package corral
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/lambda"
 	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 
@@ -23,6 +30,7 @@ func TestRunningInLambda(t *testing.T) {
 
 	for _, env := range []string{"LAMBDA_TASK_ROOT", "AWS_EXECUTION_ENV", "LAMBDA_RUNTIME_DIR"} {
 		os.Setenv(env, "value")
+		defer os.Unsetenv(env)
 	}
 
 	res = runningInLambda()
@@ -35,7 +43,7 @@ func TestHandleRequest(t *testing.T) {
 		Phase:            MapPhase,
 		BinID:            0,
 		IntermediateBins: 10,
-		Splits:           []inputSplit{},
+		Splits:           []InputSplit{},
 		FileSystemType:   corfs.Local,
 		WorkingLocation:  ".",
 	}
@@ -52,7 +60,7 @@ func TestHandleRequest(t *testing.T) {
 
 	output, err := handleRequest(context.Background(), testTask)
 	assert.Nil(t, err)
-	assert.Equal(t, "{\"BytesRead\":0,\"BytesWritten\":0}", output)
+	assert.Equal(t, "{\"BytesRead\":0,\"BytesWritten\":0,\"Shuffle\":{\"BinBytes\":{},\"TopKeys\":[]}}", output)
 
 	testTask.Phase = ReducePhase
 	output, err = handleRequest(context.Background(), testTask)
@@ -65,7 +73,7 @@ type mockLambdaClient struct {
 	capturedPayload []byte
 }
 
-func (m *mockLambdaClient) Invoke(input *lambda.InvokeInput) (*lambda.InvokeOutput, error) {
+func (m *mockLambdaClient) InvokeWithContext(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
 	m.capturedPayload = input.Payload
 	return &lambda.InvokeOutput{}, nil
 }
@@ -89,9 +97,10 @@ func TestRunLambdaMapper(t *testing.T) {
 	}
 
 	job := &Job{
-		config: &config{WorkingLocation: "."},
+		config:      &config{WorkingLocation: "."},
+		splitPoints: []string{"m"},
 	}
-	err := executor.RunMapper(job, 0, 10, []inputSplit{})
+	err := executor.RunMapper(context.Background(), job, 0, 10, 1, []InputSplit{})
 	assert.Nil(t, err)
 
 	var taskPayload task
@@ -100,6 +109,8 @@ func TestRunLambdaMapper(t *testing.T) {
 
 	assert.Equal(t, uint(10), taskPayload.BinID)
 	assert.Equal(t, MapPhase, taskPayload.Phase)
+	assert.Equal(t, 1, taskPayload.Attempt)
+	assert.Equal(t, []string{"m"}, taskPayload.SplitPoints)
 }
 
 func TestRunLambdaReducer(t *testing.T) {
@@ -113,9 +124,10 @@ func TestRunLambdaReducer(t *testing.T) {
 	}
 
 	job := &Job{
-		config: &config{WorkingLocation: "."},
+		config:           &config{WorkingLocation: ".", Params: map[string]string{"foo": "bar"}},
+		intermediateBins: 20,
 	}
-	err := executor.RunReducer(job, 0, 10)
+	err := executor.RunReducer(context.Background(), job, 0, 10, 1)
 	assert.Nil(t, err)
 
 	var taskPayload task
@@ -124,6 +136,51 @@ func TestRunLambdaReducer(t *testing.T) {
 
 	assert.Equal(t, uint(10), taskPayload.BinID)
 	assert.Equal(t, ReducePhase, taskPayload.Phase)
+	assert.Equal(t, 1, taskPayload.Attempt)
+	assert.Equal(t, uint(20), taskPayload.IntermediateBins)
+	assert.Equal(t, map[string]string{"foo": "bar"}, taskPayload.Params)
+}
+
+// failingLambdaClient is a Lambda client whose invocations always fail with a function error
+type failingLambdaClient struct {
+	mockLambdaClient
+	invocations int32
+}
+
+func (f *failingLambdaClient) InvokeWithContext(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	atomic.AddInt32(&f.invocations, 1)
+	return &lambda.InvokeOutput{
+		FunctionError: aws.String("Unhandled"),
+		Payload:       []byte(`{"errorMessage":"task failed"}`),
+	}, nil
+}
+
+func TestLambdaTaskAttempts(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("foo bar baz"), 0600)
+
+	mock := &failingLambdaClient{}
+	driver := NewDriver(NewJob(testWCJob{}, testWCJob{}),
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+	)
+	driver.executor = &lambdaExecutor{&corlambda.LambdaClient{Client: mock}, nil, "FunctionName"}
+	viper.SetDefault("lambdaManageRole", false) // Disable testing role deployment
+
+	err = driver.Run(context.Background())
+	assert.NotNil(t, err)
+
+	// Each attempt of the failed map task invokes the function exactly once
+	var taskErr *TaskError
+	assert.True(t, errors.As(err, &taskErr))
+	assert.Equal(t, 3, taskErr.Attempts)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&mock.invocations))
+	assert.EqualError(t, taskErr.Err, "Function error: task failed")
 }
 
 func TestDeployFunction(t *testing.T) {
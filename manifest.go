@@ -0,0 +1,166 @@
+package corral
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+)
+
+// manifestFile is the name of the manifest that the driver maintains in each job's working location
+const manifestFile = "_manifest.json"
+
+// jobManifest records the progress of a job, so that a failed driver run can be resumed.
+// It is written to the job's working location at the start and end of each phase.
+type jobManifest struct {
+	Inputs        []string
+	SplitSize     int64
+	MapBinSize    int64
+	ReduceBinSize int64
+	NumReducers   int
+
+	// Settings that determine the layout and encoding of the map tasks' output, which
+	// resumed reduce tasks read
+	ReducerSizing           ReducerSizing
+	IntermediateFormat      IntermediateFormat
+	IntermediateCompression Compression
+	TotalOrder              bool
+	CustomPartitioner       bool
+	SaltHotKeys             int
+
+	// MapBins holds the input splits of each map task, so that resumed map tasks
+	// read exactly the same inputs
+	MapBins             [][]InputSplit
+	IntermediateBins    uint
+	SplitPoints         []string `json:",omitempty"`
+	HotKeys             []string `json:",omitempty"`
+	CompletedMapBins    []uint
+	CompletedReduceBins []uint
+	Complete            bool
+}
+
+// newJobManifest returns the manifest of a job that hasn't been started
+func newJobManifest(inputs []string, job *Job) *jobManifest {
+	c := job.config
+	return &jobManifest{
+		Inputs:        inputs,
+		SplitSize:     c.SplitSize,
+		MapBinSize:    c.MapBinSize,
+		ReduceBinSize: c.ReduceBinSize,
+		NumReducers:   c.NumReducers,
+
+		ReducerSizing:           c.ReducerSizing,
+		IntermediateFormat:      c.IntermediateFormat,
+		IntermediateCompression: c.IntermediateCompression,
+		TotalOrder:              job.TotalOrder,
+		CustomPartitioner:       job.PartitionFunc != nil,
+		SaltHotKeys:             job.SaltHotKeys,
+	}
+}
+
+// resumableFrom returns true if a job described by m can be resumed from the progress in previous,
+// i.e. the job's inputs, the settings that determine its tasks, and the settings that
+// determine its shuffle are unchanged
+func (m *jobManifest) resumableFrom(previous *jobManifest) bool {
+	return reflect.DeepEqual(m.Inputs, previous.Inputs) &&
+		m.SplitSize == previous.SplitSize &&
+		m.MapBinSize == previous.MapBinSize &&
+		m.ReduceBinSize == previous.ReduceBinSize &&
+		m.NumReducers == previous.NumReducers &&
+		m.ReducerSizing == previous.ReducerSizing &&
+		m.IntermediateFormat == previous.IntermediateFormat &&
+		m.IntermediateCompression == previous.IntermediateCompression &&
+		m.TotalOrder == previous.TotalOrder &&
+		m.CustomPartitioner == previous.CustomPartitioner &&
+		m.SaltHotKeys == previous.SaltHotKeys
+}
+
+// pendingBins returns the IDs of the tasks of phase that haven't completed
+func (m *jobManifest) pendingBins(phase Phase) []uint {
+	numBins := m.IntermediateBins
+	completedBins := m.CompletedReduceBins
+	if phase == MapPhase {
+		numBins = uint(len(m.MapBins))
+		completedBins = m.CompletedMapBins
+	}
+
+	completed := make(map[uint]bool, len(completedBins))
+	for _, binID := range completedBins {
+		completed[binID] = true
+	}
+
+	pending := make([]uint, 0, numBins)
+	for binID := uint(0); binID < numBins; binID++ {
+		if !completed[binID] {
+			pending = append(pending, binID)
+		}
+	}
+	return pending
+}
+
+// hotKeysMerged returns true if the partial results of the job's hot keys have been merged
+func (m *jobManifest) hotKeysMerged() bool {
+	for _, binID := range m.CompletedReduceBins {
+		if binID == m.IntermediateBins {
+			return true
+		}
+	}
+	return false
+}
+
+// complete records that the given tasks of phase have completed
+func (m *jobManifest) complete(phase Phase, binIDs []uint) {
+	if phase == MapPhase {
+		m.CompletedMapBins = append(m.CompletedMapBins, binIDs...)
+	} else {
+		m.CompletedReduceBins = append(m.CompletedReduceBins, binIDs...)
+	}
+}
+
+// writeManifest writes the job's manifest to its working location. The manifest is written
+// even if the driver run has been cancelled, so that the run can be resumed.
+func (j *Job) writeManifest(m *jobManifest) error {
+	payload, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	writer, err := j.fileSystem.OpenWriter(context.Background(), j.fileSystem.Join(j.outputPath, manifestFile))
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(payload); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// readManifest reads the job's manifest from its working location.
+// It returns nil if the job has no manifest.
+func (j *Job) readManifest(ctx context.Context) (*jobManifest, error) {
+	path := j.fileSystem.Join(j.outputPath, manifestFile)
+	files, err := j.fileSystem.ListFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	reader, err := j.fileSystem.OpenReader(ctx, path, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	payload, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var m jobManifest
+	if err := json.Unmarshal(payload, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
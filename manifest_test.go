@@ -0,0 +1,231 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobManifestPendingBins(t *testing.T) {
+	manifest := &jobManifest{
+		MapBins:          make([][]InputSplit, 4),
+		IntermediateBins: 3,
+	}
+	assert.Equal(t, []uint{0, 1, 2, 3}, manifest.pendingBins(MapPhase))
+	assert.Equal(t, []uint{0, 1, 2}, manifest.pendingBins(ReducePhase))
+
+	manifest.complete(MapPhase, []uint{3, 1})
+	manifest.complete(ReducePhase, []uint{0})
+	assert.Equal(t, []uint{0, 2}, manifest.pendingBins(MapPhase))
+	assert.Equal(t, []uint{1, 2}, manifest.pendingBins(ReducePhase))
+}
+
+func TestJobManifestResumableFrom(t *testing.T) {
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.config = &config{SplitSize: 10, MapBinSize: 20, ReduceBinSize: 30}
+	previous := newJobManifest([]string{"foo", "bar"}, job)
+	previous.MapBins = make([][]InputSplit, 2)
+
+	assert.True(t, newJobManifest([]string{"foo", "bar"}, job).resumableFrom(previous))
+	assert.False(t, newJobManifest([]string{"foo"}, job).resumableFrom(previous))
+
+	job.config.MapBinSize = 40
+	assert.False(t, newJobManifest([]string{"foo", "bar"}, job).resumableFrom(previous))
+	job.config.MapBinSize = 20
+
+	// Map output written with different shuffle settings can't be read by resumed reduce tasks
+	job.config.IntermediateFormat = JSONFormat
+	assert.False(t, newJobManifest([]string{"foo", "bar"}, job).resumableFrom(previous))
+	job.config.IntermediateFormat = ""
+
+	job.config.IntermediateCompression = GzipCompression
+	assert.False(t, newJobManifest([]string{"foo", "bar"}, job).resumableFrom(previous))
+	job.config.IntermediateCompression = ""
+
+	job.TotalOrder = true
+	assert.False(t, newJobManifest([]string{"foo", "bar"}, job).resumableFrom(previous))
+	job.TotalOrder = false
+
+	job.SaltHotKeys = 4
+	assert.False(t, newJobManifest([]string{"foo", "bar"}, job).resumableFrom(previous))
+	job.SaltHotKeys = 0
+
+	job.PartitionFunc = func(key string, numBins uint) uint { return 0 }
+	assert.False(t, newJobManifest([]string{"foo", "bar"}, job).resumableFrom(previous))
+}
+
+func TestWriteReadManifest(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	manifest, err := job.readManifest(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, manifest)
+
+	written := &jobManifest{
+		Inputs: []string{"foo"},
+		MapBins: [][]InputSplit{
+			{{Filename: "foo", StartOffset: 0, EndOffset: 9}},
+		},
+		IntermediateBins: 2,
+		CompletedMapBins: []uint{0},
+	}
+	assert.Nil(t, job.writeManifest(written))
+
+	manifest, err = job.readManifest(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, written, manifest)
+}
+
+// recordingExecutor records the tasks that it runs, and fails the tasks in failTasks
+type recordingExecutor struct {
+	localExecutor
+	failTasks map[string]bool
+	mut       sync.Mutex
+	tasks     []string
+}
+
+func (r *recordingExecutor) run(jobNumber int, phase Phase, binID uint) error {
+	task := fmt.Sprintf("job%d-%s-%d", jobNumber, phase, binID)
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.tasks = append(r.tasks, task)
+	sort.Strings(r.tasks)
+	if r.failTasks[task] {
+		return fmt.Errorf("%s failed", task)
+	}
+	return nil
+}
+
+func (r *recordingExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	if err := r.run(jobNumber, MapPhase, binID); err != nil {
+		return err
+	}
+	return r.localExecutor.RunMapper(ctx, job, jobNumber, binID, attempt, inputSplits)
+}
+
+func (r *recordingExecutor) RunReducer(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int) error {
+	if err := r.run(jobNumber, ReducePhase, binID); err != nil {
+		return err
+	}
+	return r.localExecutor.RunReducer(ctx, job, jobNumber, binID, attempt)
+}
+
+func TestRunResume(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputDir := filepath.Join(tmpdir, "input")
+	assert.Nil(t, os.Mkdir(inputDir, 0700))
+	for i := 0; i < 2; i++ {
+		inputPath := filepath.Join(inputDir, fmt.Sprintf("test_input%d", i))
+		ioutil.WriteFile(inputPath, []byte("the test input the"), 0700)
+	}
+
+	newDriver := func(options ...Option) *Driver {
+		options = append([]Option{
+			WithInputs(inputDir),
+			WithWorkingLocation(tmpdir),
+			WithMapBinSize(18),
+			WithSplitSize(18),
+			WithRetryPolicy(NoRetries),
+		}, options...)
+		mr := &testFilterJob{prefix: "t"}
+		driver := NewMultiStageDriver([]*Job{NewJob(testWCJob{}, testWCJob{}), NewJob(mr, mr)}, options...)
+		driver.config.MaxConcurrency = 1
+		return driver
+	}
+
+	// The first run fails in the second job
+	driver := newDriver()
+	executor := &recordingExecutor{failTasks: map[string]bool{"job1-map-1": true}}
+	driver.executor = executor
+	assert.NotNil(t, driver.Run(context.Background()))
+	assert.Equal(t, []string{"job0-map-0", "job0-map-1", "job0-reduce-0", "job1-map-0", "job1-map-1"}, executor.tasks)
+
+	// Resuming without WithResume is refused, as output exists
+	driver = newDriver()
+	driver.executor = &recordingExecutor{}
+	assert.NotNil(t, driver.Run(context.Background()))
+
+	// The completed job, and completed tasks of the failed job, are skipped
+	driver = newDriver(WithResume())
+	executor = &recordingExecutor{}
+	driver.executor = executor
+	assert.Nil(t, driver.Run(context.Background()))
+	assert.Equal(t, []string{"job1-map-1", "job1-reduce-0"}, executor.tasks)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "job1", "output-part-0"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []keyValue{
+		{"the", "4"},
+		{"test", "2"},
+	}, testOutputToKeyValues(string(output)))
+
+	// Resuming a completed run does nothing
+	driver = newDriver(WithResume())
+	executor = &recordingExecutor{}
+	driver.executor = executor
+	assert.Nil(t, driver.Run(context.Background()))
+	assert.Empty(t, executor.tasks)
+}
+
+func TestRunResumeFailedTasks(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputDir := filepath.Join(tmpdir, "input")
+	assert.Nil(t, os.Mkdir(inputDir, 0700))
+	for i := 0; i < 3; i++ {
+		inputPath := filepath.Join(inputDir, fmt.Sprintf("test_input%d", i))
+		ioutil.WriteFile(inputPath, []byte("foo bar baz"), 0700)
+	}
+
+	newDriver := func(options ...Option) *Driver {
+		options = append([]Option{
+			WithInputs(inputDir),
+			WithWorkingLocation(tmpdir),
+			WithMapBinSize(11),
+			WithSplitSize(11),
+			WithRetryPolicy(NoRetries),
+		}, options...)
+		driver := NewDriver(NewJob(testWCJob{}, testWCJob{}), options...)
+		driver.config.MaxConcurrency = 1
+		return driver
+	}
+
+	driver := newDriver()
+	driver.executor = &recordingExecutor{failTasks: map[string]bool{"job0-map-1": true}}
+	assert.NotNil(t, driver.Run(context.Background()))
+
+	// Only the map tasks that didn't complete are run
+	driver = newDriver(WithResume())
+	executor := &recordingExecutor{}
+	driver.executor = executor
+	assert.Nil(t, driver.Run(context.Background()))
+	assert.NotContains(t, executor.tasks, "job0-map-0")
+	assert.Contains(t, executor.tasks, "job0-map-1")
+	assert.Contains(t, executor.tasks, "job0-reduce-0")
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []keyValue{
+		{"foo", "3"},
+		{"bar", "3"},
+		{"baz", "3"},
+	}, testOutputToKeyValues(string(output)))
+
+	// Resuming with different settings is refused
+	driver = newDriver(WithResume(), WithMapBinSize(22))
+	driver.executor = &recordingExecutor{}
+	assert.NotNil(t, driver.Run(context.Background()))
+}
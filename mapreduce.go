@@ -23,16 +23,122 @@ type Mapper interface {
 	Map(key, value string, emitter Emitter)
 }
 
+// FieldsMapper is an optional interface for Mappers of delimited input (such as CSVInputFormat).
+// If a job's Mapper implements FieldsMapper and its InputFormat's RecordReader implements
+// FieldsRecordReader, MapFields is called with the parsed fields of each record instead of Map.
+// header is the header row of the record's file, or nil if the file has no header.
+type FieldsMapper interface {
+	MapFields(header, fields []string, emitter Emitter)
+}
+
+// RecordMapper is an optional interface for Mappers of decoded input records (such as
+// JSONLinesInputFormat). If a job's Mapper implements RecordMapper and its InputFormat's
+// RecordReader implements DecodedRecordReader, MapRecord is called with each decoded record
+// instead of Map.
+type RecordMapper interface {
+	MapRecord(key string, record interface{}, emitter Emitter)
+}
+
 // Reducer defines the interface for a Reduce task.
 type Reducer interface {
 	Reduce(key string, values ValueIterator, emitter Emitter)
 }
 
+// MapperE is a variant of Mapper whose Map can fail. An error returned by Map fails the map
+// task, unless it wraps ErrMalformedRecord, in which case the record is skipped (and counted)
+// like other malformed records. Use AdaptMapperE to set a MapperE as a job's Mapper.
+type MapperE interface {
+	Map(key, value string, emitter Emitter) error
+}
+
+// FieldsMapperE is a variant of FieldsMapper whose MapFields can fail, as with MapperE
+type FieldsMapperE interface {
+	MapFields(header, fields []string, emitter Emitter) error
+}
+
+// RecordMapperE is a variant of RecordMapper whose MapRecord can fail, as with MapperE
+type RecordMapperE interface {
+	MapRecord(key string, record interface{}, emitter Emitter) error
+}
+
+// ReducerE is a variant of Reducer whose Reduce can fail. An error returned by Reduce fails
+// the reduce task. Use AdaptReducerE to set a ReducerE as a job's Reducer (or Combine function).
+type ReducerE interface {
+	Reduce(key string, values ValueIterator, emitter Emitter) error
+}
+
+// AdaptMapperE adapts a MapperE to the Mapper interface, so that it can be used as a job's
+// Mapper. The MapperE may also implement FieldsMapperE or RecordMapperE, and any of the
+// optional interfaces of Mappers (such as SetupHook).
+func AdaptMapperE(mapper MapperE) Mapper {
+	return mapperE{mapper}
+}
+
+// AdaptReducerE adapts a ReducerE to the Reducer interface, so that it can be used as a job's
+// Reducer or Combine function. The ReducerE may also implement the optional interfaces of
+// Reducers (such as SetupHook).
+func AdaptReducerE(reducer ReducerE) Reducer {
+	return reducerE{reducer}
+}
+
+// mapperE adapts a MapperE to the Mapper interface
+type mapperE struct {
+	mapper MapperE
+}
+
+// Map calls the adapted Map, and panics if it fails. Jobs call the adapted Map directly,
+// so that its errors fail the map task rather than panicking.
+func (m mapperE) Map(key, value string, emitter Emitter) {
+	if err := m.mapper.Map(key, value, emitter); err != nil {
+		panic(err)
+	}
+}
+
+// reducerE adapts a ReducerE to the Reducer interface
+type reducerE struct {
+	reducer ReducerE
+}
+
+// Reduce calls the adapted Reduce, and panics if it fails. Jobs call the adapted Reduce
+// directly, so that its errors fail the reduce task rather than panicking.
+func (r reducerE) Reduce(key string, values ValueIterator, emitter Emitter) {
+	if err := r.reducer.Reduce(key, values, emitter); err != nil {
+		panic(err)
+	}
+}
+
+// unwrapAdapter returns the MapperE or ReducerE adapted by v, or v if it isn't an adapter
+func unwrapAdapter(v interface{}) interface{} {
+	switch adapter := v.(type) {
+	case mapperE:
+		return adapter.mapper
+	case reducerE:
+		return adapter.reducer
+	}
+	return v
+}
+
+// reduceFunc returns a function that calls reducer's Reduce, and returns its error (if any)
+func reduceFunc(reducer Reducer) func(key string, values ValueIterator, emitter Emitter) error {
+	if adapter, ok := reducer.(reducerE); ok {
+		return adapter.reducer.Reduce
+	}
+	return func(key string, values ValueIterator, emitter Emitter) error {
+		reducer.Reduce(key, values, emitter)
+		return nil
+	}
+}
+
 // PartitionFunc defines a function that can be used to segment map keys into intermediate buckets.
 // The default partition function simply hashes the key, and takes hash % numBins to determine the bin.
 // The value returned from PartitionFunc (binIdx) must be in the range 0 <= binIdx < numBins, i.e. [0, numBins)
 type PartitionFunc func(key string, numBins uint) (binIdx uint)
 
+// Comparator defines an ordering of keys or values. It returns a negative number
+// if a sorts before b, zero if a and b are equivalent, and a positive number
+// if a sorts after b. strings.Compare is a valid Comparator.
+type Comparator func(a, b string) int
+
 // keyValue is used to store intermediate shuffle data as key-value pairs
 type keyValue struct {
 	Key   string `json:"key"`
@@ -22,3 +22,25 @@ func TestValueIterator(t *testing.T) {
 		i++
 	}
 }
+
+func TestAdaptMapperE(t *testing.T) {
+	mapper := AdaptMapperE(testErrorJob{})
+	assert.Equal(t, testErrorJob{}, unwrapAdapter(mapper))
+	assert.Equal(t, testWCJob{}, unwrapAdapter(testWCJob{}))
+
+	// Errors fail tasks, so the adapter panics if it is called directly
+	assert.Panics(t, func() {
+		mapper.Map("", "bad", &sizingEmitter{})
+	})
+}
+
+func TestAdaptReducerE(t *testing.T) {
+	reducer := AdaptReducerE(testErrorJob{})
+	assert.Equal(t, testErrorJob{}, unwrapAdapter(reducer))
+
+	values := make(chan string)
+	close(values)
+	err := reduceFunc(reducer)("bad", newValueIterator(values), &sizingEmitter{})
+	assert.Equal(t, errBadWord, err)
+	assert.Nil(t, reduceFunc(testWCJob{})("bad", newValueIterator(values), &sizingEmitter{}))
+}
@@ -0,0 +1,211 @@
+package corral
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// OutputFormat controls how the records emitted by reducers are written to output files.
+type OutputFormat interface {
+	// Extension returns the file extension (e.g. ".csv") of output files, or an empty string.
+	Extension() string
+	// NewRecordWriter returns a RecordWriter that writes records to w.
+	NewRecordWriter(w io.Writer) (RecordWriter, error)
+}
+
+// RecordWriter writes key/value records to a single output file.
+// Writes are serialized by the framework, so RecordWriters needn't be threadsafe.
+type RecordWriter interface {
+	// Write writes a single record.
+	Write(key, value string) error
+	// Close flushes any buffered records. It does not close the underlying writer.
+	Close() error
+}
+
+// TextOutputFormat writes each record as a line containing its key and value, separated
+// by a tab. Output written by TextOutputFormat can be read by TextInputFormat.
+// This is the default OutputFormat.
+type TextOutputFormat struct{}
+
+// Extension returns an empty string, as text output files have no extension
+func (TextOutputFormat) Extension() string {
+	return ""
+}
+
+// NewRecordWriter returns a RecordWriter of tab-separated records
+func (TextOutputFormat) NewRecordWriter(w io.Writer) (RecordWriter, error) {
+	return &textRecordWriter{writer: w}, nil
+}
+
+type textRecordWriter struct {
+	writer io.Writer
+}
+
+func (t *textRecordWriter) Write(key, value string) error {
+	_, err := fmt.Fprintf(t.writer, "%s\t%s\n", key, value)
+	return err
+}
+
+func (t *textRecordWriter) Close() error {
+	return nil
+}
+
+// ValueOutputFormat writes the value of each record on its own line, discarding keys.
+type ValueOutputFormat struct{}
+
+// Extension returns an empty string, as value output files have no extension
+func (ValueOutputFormat) Extension() string {
+	return ""
+}
+
+// NewRecordWriter returns a RecordWriter of record values
+func (ValueOutputFormat) NewRecordWriter(w io.Writer) (RecordWriter, error) {
+	return &valueRecordWriter{writer: bufio.NewWriter(w)}, nil
+}
+
+type valueRecordWriter struct {
+	writer *bufio.Writer
+}
+
+func (v *valueRecordWriter) Write(key, value string) error {
+	if _, err := v.writer.WriteString(value); err != nil {
+		return err
+	}
+	return v.writer.WriteByte('\n')
+}
+
+func (v *valueRecordWriter) Close() error {
+	return v.writer.Flush()
+}
+
+// JSONLinesOutputFormat writes each record as a JSON object on its own line
+// (e.g. {"key":"foo","value":"bar"}). Output written by JSONLinesOutputFormat can be read
+// by JSONLinesInputFormat.
+type JSONLinesOutputFormat struct {
+	// KeyField is the name of the field that holds each record's key. Defaults to "key".
+	KeyField string
+	// ValueField is the name of the field that holds each record's value. Defaults to "value".
+	ValueField string
+	// JSONValues indicates that emitted values are JSON documents, which are embedded
+	// in output records as-is, rather than as strings. Values that aren't valid JSON cause
+	// the reducer to fail.
+	JSONValues bool
+}
+
+// Extension returns ".json"
+func (JSONLinesOutputFormat) Extension() string {
+	return ".json"
+}
+
+// NewRecordWriter returns a RecordWriter of JSON records
+func (j JSONLinesOutputFormat) NewRecordWriter(w io.Writer) (RecordWriter, error) {
+	keyField, valueField := j.KeyField, j.ValueField
+	if keyField == "" {
+		keyField = "key"
+	}
+	if valueField == "" {
+		valueField = "value"
+	}
+	if keyField == valueField {
+		return nil, fmt.Errorf("JSONLinesOutputFormat KeyField and ValueField must differ (both are '%s')", keyField)
+	}
+
+	// Field names are encoded once, rather than for every record
+	keyName, _ := json.Marshal(keyField)
+	valueName, _ := json.Marshal(valueField)
+
+	return &jsonLinesRecordWriter{
+		writer:     bufio.NewWriter(w),
+		keyName:    keyName,
+		valueName:  valueName,
+		jsonValues: j.JSONValues,
+	}, nil
+}
+
+type jsonLinesRecordWriter struct {
+	writer     *bufio.Writer
+	keyName    []byte
+	valueName  []byte
+	jsonValues bool
+}
+
+func (j *jsonLinesRecordWriter) Write(key, value string) error {
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+
+	var encodedValue []byte
+	if j.jsonValues {
+		if !json.Valid([]byte(value)) {
+			return fmt.Errorf("Value of key '%s' is not valid JSON", key)
+		}
+		encodedValue = []byte(value)
+	} else if encodedValue, err = json.Marshal(value); err != nil {
+		return err
+	}
+
+	j.writer.WriteByte('{')
+	j.writer.Write(j.keyName)
+	j.writer.WriteByte(':')
+	j.writer.Write(encodedKey)
+	j.writer.WriteByte(',')
+	j.writer.Write(j.valueName)
+	j.writer.WriteByte(':')
+	j.writer.Write(encodedValue)
+	j.writer.WriteByte('}')
+	return j.writer.WriteByte('\n')
+}
+
+func (j *jsonLinesRecordWriter) Close() error {
+	return j.writer.Flush()
+}
+
+// CSVOutputFormat writes each record as a CSV row of two fields: its key and its value.
+// Fields are quoted as necessary. Output written by CSVOutputFormat can be read by CSVInputFormat.
+type CSVOutputFormat struct {
+	// Comma is the field delimiter. Defaults to ','.
+	Comma rune
+	// Header optionally names the key and value columns. If set, it is written as the
+	// first row of each output file.
+	Header []string
+}
+
+// Extension returns ".csv"
+func (CSVOutputFormat) Extension() string {
+	return ".csv"
+}
+
+// NewRecordWriter returns a RecordWriter of CSV rows
+func (c CSVOutputFormat) NewRecordWriter(w io.Writer) (RecordWriter, error) {
+	writer := csv.NewWriter(w)
+	if c.Comma != 0 {
+		writer.Comma = c.Comma
+	}
+
+	if c.Header != nil {
+		if len(c.Header) != 2 {
+			return nil, fmt.Errorf("CSVOutputFormat Header must have 2 columns, not %d", len(c.Header))
+		}
+		if err := writer.Write(c.Header); err != nil {
+			return nil, err
+		}
+	}
+	return &csvRecordWriter{writer: writer}, nil
+}
+
+type csvRecordWriter struct {
+	writer *csv.Writer
+}
+
+func (c *csvRecordWriter) Write(key, value string) error {
+	return c.writer.Write([]string{key, value})
+}
+
+func (c *csvRecordWriter) Close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
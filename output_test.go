@@ -0,0 +1,73 @@
+package corral
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeRecords(t *testing.T, format OutputFormat, records []keyValue) string {
+	buf := new(bytes.Buffer)
+	writer, err := format.NewRecordWriter(buf)
+	assert.Nil(t, err)
+
+	for _, kv := range records {
+		assert.Nil(t, writer.Write(kv.Key, kv.Value))
+	}
+	assert.Nil(t, writer.Close())
+	return buf.String()
+}
+
+var testOutputRecords = []keyValue{
+	{"foo", "bar"},
+	{"a,b", "\"quoted\"\ttab"},
+	{"", ""},
+}
+
+func TestTextOutputFormat(t *testing.T) {
+	assert.Equal(t, "", TextOutputFormat{}.Extension())
+	output := writeRecords(t, TextOutputFormat{}, testOutputRecords)
+	assert.Equal(t, "foo\tbar\na,b\t\"quoted\"\ttab\n\t\n", output)
+}
+
+func TestValueOutputFormat(t *testing.T) {
+	output := writeRecords(t, ValueOutputFormat{}, testOutputRecords)
+	assert.Equal(t, "bar\n\"quoted\"\ttab\n\n", output)
+}
+
+func TestJSONLinesOutputFormat(t *testing.T) {
+	assert.Equal(t, ".json", JSONLinesOutputFormat{}.Extension())
+	output := writeRecords(t, JSONLinesOutputFormat{}, testOutputRecords)
+	assert.Equal(t, `{"key":"foo","value":"bar"}
+{"key":"a,b","value":"\"quoted\"\ttab"}
+{"key":"","value":""}
+`, output)
+
+	format := JSONLinesOutputFormat{
+		KeyField:   "word",
+		ValueField: "stats",
+		JSONValues: true,
+	}
+	output = writeRecords(t, format, []keyValue{{"foo", `{"count": 1}`}})
+	assert.Equal(t, "{\"word\":\"foo\",\"stats\":{\"count\": 1}}\n", output)
+
+	writer, err := format.NewRecordWriter(new(bytes.Buffer))
+	assert.Nil(t, err)
+	assert.NotNil(t, writer.Write("foo", "not json"))
+
+	_, err = JSONLinesOutputFormat{KeyField: "value"}.NewRecordWriter(new(bytes.Buffer))
+	assert.NotNil(t, err)
+}
+
+func TestCSVOutputFormat(t *testing.T) {
+	assert.Equal(t, ".csv", CSVOutputFormat{}.Extension())
+	output := writeRecords(t, CSVOutputFormat{Header: []string{"k", "v"}}, testOutputRecords)
+	assert.Equal(t, "k,v\nfoo,bar\n\"a,b\",\"\"\"quoted\"\"\ttab\"\n,\n", output)
+
+	output = writeRecords(t, CSVOutputFormat{Comma: '|'}, testOutputRecords[:1])
+	assert.Equal(t, "foo|bar\n", output)
+
+	_, err := CSVOutputFormat{Header: []string{"k"}}.NewRecordWriter(new(bytes.Buffer))
+	assert.NotNil(t, err)
+}
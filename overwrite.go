@@ -0,0 +1,80 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+// OverwriteMode controls how a Driver treats output left at its working location by an earlier run
+type OverwriteMode string
+
+// Supported overwrite modes
+const (
+	// NoOverwrite causes the Driver to fail, rather than overwrite existing output
+	NoOverwrite OverwriteMode = "none"
+	// OverwriteOutput allows existing output to be overwritten. Stale files that aren't
+	// overwritten (e.g. output parts of reducers that no longer exist) are left in place.
+	OverwriteOutput OverwriteMode = "overwrite"
+	// DeleteOutput deletes existing output before any job is run
+	DeleteOutput OverwriteMode = "delete"
+)
+
+// ErrOutputExists is wrapped by the error that a Driver returns when its working location
+// already contains output, and overwriting it wasn't requested.
+var ErrOutputExists = errors.New("Output already exists")
+
+// existingOutput returns the output files of earlier runs at the working location of the job at idx
+func (d *Driver) existingOutput(fs corfs.FileSystem, idx int) ([]corfs.FileInfo, error) {
+	existing := make([]corfs.FileInfo, 0)
+	jobWorkingLoc := d.jobWorkingLocation(fs, idx)
+	patterns := []string{"output-*", "map-bin*", successMarker, manifestFile, fs.Join(temporaryDir, "*", "*")}
+	for _, pattern := range patterns {
+		files, err := fs.ListFiles(fs.Join(jobWorkingLoc, pattern))
+		if err != nil {
+			return nil, err
+		}
+		existing = append(existing, files...)
+	}
+	return existing, nil
+}
+
+// checkExistingOutput fails if the driver's jobs would overwrite the output of an earlier run,
+// unless overwriting was requested. In DeleteOutput mode, existing output is deleted instead.
+func (d *Driver) checkExistingOutput(ctx context.Context) error {
+	for idx, job := range d.jobs {
+		location := job.config.WorkingLocation
+		fs := corfs.InferFilesystem(location)
+		existing, err := d.existingOutput(fs, idx)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			continue
+		}
+
+		switch d.config.Overwrite {
+		case OverwriteOutput:
+			log.Warnf("Overwriting existing output at %s", location)
+		case DeleteOutput:
+			log.Infof("Deleting %d existing output file(s) at %s", len(existing), location)
+			for _, file := range existing {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := fs.Delete(file.Name); err != nil {
+					return err
+				}
+			}
+		case NoOverwrite, "":
+			return fmt.Errorf("%w at %s (e.g. %s). Use --overwrite to replace it", ErrOutputExists, location, existing[0].Name)
+		default:
+			return fmt.Errorf("Unknown overwrite mode: '%s'", d.config.Overwrite)
+		}
+	}
+	return nil
+}
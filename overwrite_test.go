@@ -0,0 +1,111 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testOverwriteDriver(t *testing.T, tmpdir string, options ...Option) *Driver {
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input"), 0700)
+
+	options = append([]Option{WithInputs(inputPath), WithWorkingLocation(tmpdir)}, options...)
+	return NewDriver(NewJob(testWCJob{}, testWCJob{}), options...)
+}
+
+func TestRunRefusesToOverwriteOutput(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	outputPath := filepath.Join(tmpdir, "output-part-0")
+	ioutil.WriteFile(outputPath, []byte("last week's results"), 0600)
+
+	err = testOverwriteDriver(t, tmpdir).Run(context.Background())
+	assert.True(t, errors.Is(err, ErrOutputExists))
+
+	output, err := ioutil.ReadFile(outputPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "last week's results", string(output))
+}
+
+func TestRunRefusesToOverwriteMultiStageOutput(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	assert.Nil(t, os.MkdirAll(filepath.Join(tmpdir, "job1"), 0700))
+	ioutil.WriteFile(filepath.Join(tmpdir, "job1", "map-bin0-0.out"), []byte{}, 0600)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input"), 0700)
+	driver := NewMultiStageDriver([]*Job{NewJob(testWCJob{}, testWCJob{}), NewJob(testWCJob{}, testWCJob{})},
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+	)
+
+	err = driver.Run(context.Background())
+	assert.True(t, errors.Is(err, ErrOutputExists))
+}
+
+func TestRunOverwriteOutput(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	assert.Nil(t, testOverwriteDriver(t, tmpdir).Run(context.Background()))
+
+	// Stale output parts aren't removed when overwriting
+	stalePath := filepath.Join(tmpdir, "output-part-1")
+	ioutil.WriteFile(stalePath, []byte("stale"), 0600)
+
+	err = testOverwriteDriver(t, tmpdir, WithOverwrite(OverwriteOutput)).Run(context.Background())
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Len(t, testOutputToKeyValues(string(output)), 3)
+	_, err = os.Stat(stalePath)
+	assert.Nil(t, err)
+}
+
+func TestRunDeleteOutput(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	stalePath := filepath.Join(tmpdir, "output-part-1")
+	ioutil.WriteFile(stalePath, []byte("stale"), 0600)
+	ioutil.WriteFile(filepath.Join(tmpdir, "_SUCCESS"), []byte{}, 0600)
+
+	err = testOverwriteDriver(t, tmpdir, WithOverwrite(DeleteOutput)).Run(context.Background())
+	assert.Nil(t, err)
+
+	_, err = os.Stat(stalePath)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	_, err = os.Stat(filepath.Join(tmpdir, "_SUCCESS"))
+	assert.Nil(t, err)
+
+	// Inputs aren't deleted
+	_, err = os.Stat(filepath.Join(tmpdir, "test_input"))
+	assert.Nil(t, err)
+}
+
+func TestRunUnknownOverwriteMode(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	ioutil.WriteFile(filepath.Join(tmpdir, "output-part-0"), []byte{}, 0600)
+
+	err = testOverwriteDriver(t, tmpdir, WithOverwrite("clobber")).Run(context.Background())
+	assert.EqualError(t, err, "Unknown overwrite mode: 'clobber'")
+}
@@ -0,0 +1,85 @@
+package corral
+
+import (
+	"context"
+	"math"
+
+	humanize "github.com/dustin/go-humanize"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReducerSizing controls how the number of reducers of a job is determined, when it isn't
+// set explicitly with WithNumReducers
+type ReducerSizing string
+
+// Supported reducer sizing modes
+const (
+	// InputSizing sizes reducers from the total size of the job's input
+	InputSizing ReducerSizing = "input"
+	// SampledSizing sizes reducers from the estimated size of the job's map output. The output
+	// size is estimated by running the job's Mapper over a sample of its input splits in the driver.
+	SampledSizing ReducerSizing = "sample"
+)
+
+// numReducers returns the number of reduce tasks (i.e. intermediate bins) to use for a job
+// with the given input splits. Unless the number of reducers is configured, reducers are sized
+// so that each reads an expected ReduceBinSize bytes.
+func (j *Job) numReducers(ctx context.Context, splits []InputSplit) uint {
+	if j.config.NumReducers > 0 {
+		return uint(j.config.NumReducers)
+	}
+
+	var totalSize int64
+	for _, split := range splits {
+		totalSize += split.Size()
+	}
+	if j.config.ReducerSizing == SampledSizing {
+		if estimate, ok := j.estimateMapOutputSize(ctx, splits, totalSize); ok {
+			log.Debugf("Estimated map output size: %s (input size: %s)", humanize.Bytes(uint64(estimate)), humanize.Bytes(uint64(totalSize)))
+			totalSize = estimate
+		}
+	}
+
+	numBins := uint(float64(totalSize/j.config.ReduceBinSize) * 1.25)
+	if numBins == 0 {
+		numBins = 1
+	}
+	return numBins
+}
+
+// estimateMapOutputSize estimates the total size of the job's map output by sampling the
+// Mapper's output, and scaling its size by the fraction of the input that was sampled.
+// It returns false if no input could be sampled.
+func (j *Job) estimateMapOutputSize(ctx context.Context, splits []InputSplit, totalSize int64) (int64, bool) {
+	emitter := &sizingEmitter{}
+	sampledSize, err := j.sampleMapOutput(ctx, splits, emitter)
+	if err != nil {
+		log.Warnf("Unable to sample map output, so reducers are sized from input size: %s", err)
+		return 0, false
+	}
+	if sampledSize == 0 {
+		return 0, false
+	}
+
+	ratio := float64(emitter.size) / float64(sampledSize)
+	return int64(math.Ceil(ratio * float64(totalSize))), true
+}
+
+// sizingEmitter discards emitted records, recording only their total size
+type sizingEmitter struct {
+	counterSet
+	size int64
+}
+
+func (s *sizingEmitter) Emit(key, value string) error {
+	s.size += int64(len(key) + len(value))
+	return nil
+}
+
+func (s *sizingEmitter) close() error {
+	return nil
+}
+
+func (s *sizingEmitter) bytesWritten() int64 {
+	return s.size
+}
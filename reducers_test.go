@@ -0,0 +1,81 @@
+package corral
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+// testPaddingJob emits each word of its input with a large value
+type testPaddingJob struct{}
+
+func (testPaddingJob) Map(key, value string, emitter Emitter) {
+	for _, word := range strings.Fields(value) {
+		emitter.Emit(word, strings.Repeat("x", 100))
+	}
+}
+
+func TestNumReducers(t *testing.T) {
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.config = &config{ReduceBinSize: 100}
+
+	splits := []InputSplit{
+		{Filename: "a", StartOffset: 0, EndOffset: 499},
+		{Filename: "a", StartOffset: 500, EndOffset: 999},
+	}
+	assert.Equal(t, uint(12), job.numReducers(context.Background(), splits))
+	assert.Equal(t, uint(1), job.numReducers(context.Background(), splits[:0]))
+
+	job.config.NumReducers = 3
+	assert.Equal(t, uint(3), job.numReducers(context.Background(), splits))
+}
+
+func TestNumReducersSampled(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte(strings.Repeat("foo bar baz\n", 1000)), 0600)
+
+	job := NewJob(testPaddingJob{}, testWCJob{})
+	job.fileSystem = &corfs.LocalFileSystem{}
+	job.config = &config{ReduceBinSize: 12000}
+	splits := job.inputSplits(context.Background(), []string{inputPath}, 1000)
+
+	assert.Equal(t, uint(1), job.numReducers(context.Background(), splits))
+
+	job.config.ReducerSizing = SampledSizing
+	assert.True(t, job.numReducers(context.Background(), splits) > 10)
+
+	// Sampling doesn't count towards the job's statistics
+	assert.Equal(t, int64(0), job.bytesRead)
+}
+
+func TestRunWithNumReducers(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("the quick brown fox jumps over the lazy dog"), 0600)
+
+	driver := NewDriver(NewJob(testWCJob{}, testWCJob{}),
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithNumReducers(3),
+	)
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	parts, err := filepath.Glob(filepath.Join(tmpdir, "output-part-*"))
+	assert.Nil(t, err)
+	assert.Len(t, parts, 3)
+}
@@ -0,0 +1,79 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how failed map and reduce tasks are retried. Each retry is run as a
+// new attempt of the task, so the output of failed attempts is never committed.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times that a task is run. Values less than 1
+	// are treated as 1 (i.e. failed tasks aren't retried).
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry of a task. The delay doubles
+	// after each failed attempt, up to MaxBackoff. Delays are randomly jittered by up to
+	// half of their length, so that tasks which fail together don't retry in lockstep.
+	InitialBackoff time.Duration
+	// MaxBackoff is the maximum delay between attempts of a task.
+	MaxBackoff time.Duration
+	// Retryable optionally classifies task errors as transient (retryable) or permanent.
+	// By default, all errors other than context cancellation are retried.
+	Retryable func(err error) bool
+}
+
+// NoRetries is a RetryPolicy that doesn't retry failed tasks
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+// maxAttempts returns the maximum number of times a task is run
+func (r RetryPolicy) maxAttempts() int {
+	if r.MaxAttempts < 1 {
+		return 1
+	}
+	return r.MaxAttempts
+}
+
+// retryable returns true if a task that failed with err should be retried
+func (r RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if r.Retryable != nil {
+		return r.Retryable(err)
+	}
+	return true
+}
+
+// backoff returns the delay before retrying a task that has failed the given number of times
+func (r RetryPolicy) backoff(failures int) time.Duration {
+	backoff := r.InitialBackoff
+	for i := 1; i < failures && (r.MaxBackoff <= 0 || backoff < r.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if r.MaxBackoff > 0 && backoff > r.MaxBackoff {
+		backoff = r.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+
+	// "Equal" jitter: wait for at least half of the backoff
+	half := int64(backoff / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// sleepContext waits for d to elapse, or ctx to be done, whichever happens first.
+// It returns ctx's error if ctx is done before d elapses.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
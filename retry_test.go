@@ -0,0 +1,167 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicyMaxAttempts(t *testing.T) {
+	assert.Equal(t, 1, RetryPolicy{}.maxAttempts())
+	assert.Equal(t, 1, NoRetries.maxAttempts())
+	assert.Equal(t, 5, RetryPolicy{MaxAttempts: 5}.maxAttempts())
+}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := RetryPolicy{}
+	assert.True(t, policy.retryable(errors.New("connection reset")))
+	assert.False(t, policy.retryable(context.Canceled))
+	assert.False(t, policy.retryable(fmt.Errorf("read: %w", context.DeadlineExceeded)))
+
+	errPermanent := errors.New("permanent")
+	policy.Retryable = func(err error) bool {
+		return !errors.Is(err, errPermanent)
+	}
+	assert.True(t, policy.retryable(errors.New("connection reset")))
+	assert.False(t, policy.retryable(errPermanent))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}
+
+	for _, test := range []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	} {
+		for i := 0; i < 10; i++ {
+			backoff := policy.backoff(test.failures)
+			assert.True(t, backoff >= test.expected/2, "%s < %s", backoff, test.expected/2)
+			assert.True(t, backoff <= test.expected, "%s > %s", backoff, test.expected)
+		}
+	}
+
+	assert.Equal(t, time.Duration(0), RetryPolicy{}.backoff(3))
+}
+
+func TestSleepContext(t *testing.T) {
+	assert.Nil(t, sleepContext(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, sleepContext(ctx, time.Hour))
+}
+
+// flakyExecutor fails the first attempts of each task after writing partial output
+type flakyExecutor struct {
+	localExecutor
+	failures int
+	mut      sync.Mutex
+	attempts map[string]int
+}
+
+func (f *flakyExecutor) fail(job *Job, phase Phase, binID uint, attempt int) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.attempts[fmt.Sprintf("%s-%d", phase, binID)]++
+	if attempt >= f.failures {
+		return nil
+	}
+
+	// Leave partial output behind, which mustn't be committed
+	path := job.fileSystem.Join(job.attemptPath(phase, binID, attempt), "output-part-99")
+	writer, _ := job.fileSystem.OpenWriter(context.Background(), path)
+	writer.Write([]byte("partial"))
+	writer.Close()
+	return fmt.Errorf("%s %d flaked", phase, binID)
+}
+
+func (f *flakyExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	if err := f.fail(job, MapPhase, binID, attempt); err != nil {
+		return err
+	}
+	return f.localExecutor.RunMapper(ctx, job, jobNumber, binID, attempt, inputSplits)
+}
+
+func (f *flakyExecutor) RunReducer(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int) error {
+	if err := f.fail(job, ReducePhase, binID, attempt); err != nil {
+		return err
+	}
+	return f.localExecutor.RunReducer(ctx, job, jobNumber, binID, attempt)
+}
+
+func TestRunRetriesFailedTasks(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input\nthe input test\nfoo bar baz"), 0700)
+
+	driver := NewDriver(NewJob(testWCJob{}, testWCJob{}),
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+	)
+	executor := &flakyExecutor{failures: 2, attempts: make(map[string]int)}
+	driver.executor = executor
+
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"map-0": 3, "reduce-0": 3}, executor.attempts)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Len(t, testOutputToKeyValues(string(output)), 6)
+
+	// Output of failed attempts isn't committed
+	_, err = os.Stat(filepath.Join(tmpdir, "output-part-99"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(tmpdir, "_SUCCESS"))
+	assert.Nil(t, err)
+}
+
+func TestRunDoesNotRetryPermanentErrors(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "test_input")
+	ioutil.WriteFile(inputPath, []byte("the test input"), 0700)
+
+	driver := NewDriver(NewJob(testWCJob{}, testWCJob{}),
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithRetryPolicy(RetryPolicy{
+			MaxAttempts: 3,
+			Retryable:   func(error) bool { return false },
+		}),
+	)
+	executor := &flakyExecutor{failures: 2, attempts: make(map[string]int)}
+	driver.executor = executor
+
+	err = driver.Run(context.Background())
+	assert.NotNil(t, err)
+	assert.Equal(t, map[string]int{"map-0": 1}, executor.attempts)
+
+	var taskErr *TaskError
+	assert.True(t, errors.As(err, &taskErr))
+	assert.Equal(t, 1, taskErr.Attempts)
+}
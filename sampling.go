@@ -0,0 +1,69 @@
+package corral
+
+import (
+	"context"
+)
+
+var (
+	// sampleSplits is the maximum number of input splits that are sampled when the driver
+	// samples a job's map output
+	sampleSplits = 8
+	// sampleSplitSize is the maximum number of bytes read from each sampled split
+	sampleSplitSize int64 = 1024 * 1024
+)
+
+// sampleMapOutput runs the job's Mapper in the driver over the beginning of a sample of evenly
+// spaced input splits, passing its output to emitter. Compressed input files (detected by
+// extension or magic number) aren't sampled.
+// It returns the number of input bytes that were sampled, which is zero if there was no input
+// to sample. Sampling doesn't affect the job's statistics. The Mapper's Setup and Teardown
+// hooks (if any) are called with a TaskContext whose Sampling field is set.
+func (j *Job) sampleMapOutput(ctx context.Context, splits []InputSplit, emitter Emitter) (int64, error) {
+	candidates := make([]InputSplit, 0, len(splits))
+	for _, split := range splits {
+		if isCompressedInput(split.Filename) {
+			continue
+		}
+		// Compressed input is never split, so only splits at the start of a file may be compressed
+		if split.StartOffset == 0 && j.isCompressedInputFile(ctx, split.Filename) {
+			continue
+		}
+		candidates = append(candidates, split)
+	}
+	if len(candidates) == 0 {
+		return 0, nil
+	}
+
+	numSamples := sampleSplits
+	if numSamples > len(candidates) {
+		numSamples = len(candidates)
+	}
+
+	bytesRead := j.bytesRead
+	defer func() {
+		j.bytesRead = bytesRead
+	}()
+
+	samples := make([]InputSplit, numSamples)
+	for i := range samples {
+		samples[i] = candidates[i*len(candidates)/numSamples]
+		if samples[i].Size() > sampleSplitSize {
+			samples[i].EndOffset = samples[i].StartOffset + sampleSplitSize - 1
+		}
+	}
+
+	taskCtx := j.taskContext(ctx, MapPhase, 0, 0, samples)
+	taskCtx.Sampling = true
+	if err := setupTask(j.Map, taskCtx); err != nil {
+		return 0, err
+	}
+
+	var sampledSize int64
+	for _, sample := range samples {
+		if err := j.runMapperSplit(ctx, sample, emitter); err != nil {
+			return 0, teardownTask(j.Map, taskCtx, err)
+		}
+		sampledSize += sample.Size()
+	}
+	return sampledSize, teardownTask(j.Map, taskCtx, nil)
+}
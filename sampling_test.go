@@ -0,0 +1,61 @@
+package corral
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+func TestSampleMapOutput(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte(strings.Repeat("foo bar\n", 100)), 0600)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.fileSystem = &corfs.LocalFileSystem{}
+	job.config = &config{}
+	splits := job.inputSplits(context.Background(), []string{inputPath}, 80)
+	assert.Len(t, splits, 10)
+
+	emitter := &keySamplingEmitter{}
+	sampledSize, err := job.sampleMapOutput(context.Background(), splits, emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(640), sampledSize)
+	assert.True(t, len(emitter.keys) >= 160)
+	assert.Equal(t, int64(0), job.bytesRead)
+}
+
+func TestSampleMapOutputSkipsCompressedInput(t *testing.T) {
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.config = &config{}
+
+	sampledSize, err := job.sampleMapOutput(context.Background(), []InputSplit{{Filename: "input.gz", EndOffset: 99}}, &sizingEmitter{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), sampledSize)
+
+	// Compressed input without a compressed file extension is skipped too
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, gzipBytes(t, strings.Repeat("foo bar\n", 100)), 0600)
+	job.fileSystem = &corfs.LocalFileSystem{}
+	splits := job.inputSplits(context.Background(), []string{inputPath}, 1000)
+
+	emitter := &keySamplingEmitter{}
+	sampledSize, err = job.sampleMapOutput(context.Background(), splits, emitter)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), sampledSize)
+	assert.Empty(t, emitter.keys)
+}
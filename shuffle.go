@@ -0,0 +1,377 @@
+package corral
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxMergeFanIn is the maximum number of sorted runs that are merged at once.
+// If a reducer spills more runs than this, runs are merged in multiple passes.
+const maxMergeFanIn = 64
+
+// sortComparator returns the Comparator used to order keys delivered to reducers
+func (j *Job) sortComparator() Comparator {
+	if j.SortComparator != nil {
+		return j.SortComparator
+	}
+	return strings.Compare
+}
+
+// groupingComparator returns the Comparator used to group sorted keys into calls to Reduce
+func (j *Job) groupingComparator() Comparator {
+	if j.GroupingComparator != nil {
+		return j.GroupingComparator
+	}
+	return j.sortComparator()
+}
+
+// compareRecords orders intermediate records by key and then, if the job has
+// a ValueComparator, by value.
+func (j *Job) compareRecords(a, b keyValue) int {
+	if c := j.sortComparator()(a.Key, b.Key); c != 0 || j.ValueComparator == nil {
+		return c
+	}
+	return j.ValueComparator(a.Value, b.Value)
+}
+
+// sortRecords sorts intermediate records into the order in which they are delivered to reducers
+func (j *Job) sortRecords(records []keyValue) {
+	sort.SliceStable(records, func(a, b int) bool {
+		return j.compareRecords(records[a], records[b]) < 0
+	})
+}
+
+// recordIterator iterates over a sequence of intermediate records.
+// next returns io.EOF once the sequence is exhausted.
+type recordIterator interface {
+	next() (keyValue, error)
+}
+
+// sliceIterator iterates over an in-memory slice of records
+type sliceIterator struct {
+	records []keyValue
+}
+
+func (s *sliceIterator) next() (keyValue, error) {
+	if len(s.records) == 0 {
+		return keyValue{}, io.EOF
+	}
+	kv := s.records[0]
+	s.records = s.records[1:]
+	return kv, nil
+}
+
+// mergeSource is a single sorted input of a mergeIterator, along with its current record
+type mergeSource struct {
+	iter    recordIterator
+	current keyValue
+	index   int // position of the source in the merge; breaks ties so that the merge is stable
+}
+
+// mergeHeap is a min-heap of mergeSources, ordered by their current records
+type mergeHeap struct {
+	sources []*mergeSource
+	compare func(a, b keyValue) int
+}
+
+func (h *mergeHeap) Len() int { return len(h.sources) }
+
+func (h *mergeHeap) Less(a, b int) bool {
+	if c := h.compare(h.sources[a].current, h.sources[b].current); c != 0 {
+		return c < 0
+	}
+	return h.sources[a].index < h.sources[b].index
+}
+
+func (h *mergeHeap) Swap(a, b int) { h.sources[a], h.sources[b] = h.sources[b], h.sources[a] }
+
+func (h *mergeHeap) Push(x interface{}) { h.sources = append(h.sources, x.(*mergeSource)) }
+
+func (h *mergeHeap) Pop() interface{} {
+	last := h.sources[len(h.sources)-1]
+	h.sources = h.sources[:len(h.sources)-1]
+	return last
+}
+
+// mergeIterator performs a k-way merge of sorted recordIterators
+type mergeIterator struct {
+	heap *mergeHeap
+}
+
+func newMergeIterator(compare func(a, b keyValue) int, iters ...recordIterator) (*mergeIterator, error) {
+	h := &mergeHeap{compare: compare}
+	for i, iter := range iters {
+		kv, err := iter.next()
+		if err == io.EOF {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		h.sources = append(h.sources, &mergeSource{iter: iter, current: kv, index: i})
+	}
+	heap.Init(h)
+	return &mergeIterator{heap: h}, nil
+}
+
+func (m *mergeIterator) next() (keyValue, error) {
+	if m.heap.Len() == 0 {
+		return keyValue{}, io.EOF
+	}
+
+	source := m.heap.sources[0]
+	kv := source.current
+
+	next, err := source.iter.next()
+	if err == io.EOF {
+		heap.Pop(m.heap)
+	} else if err != nil {
+		return keyValue{}, err
+	} else {
+		source.current = next
+		heap.Fix(m.heap, 0)
+	}
+	return kv, nil
+}
+
+// externalSorter sorts intermediate records using a bounded amount of memory.
+// Records are buffered in memory until maxBufferSize is reached, at which point
+// the buffer is sorted and spilled to a "run" file. The sorted output is produced
+// by merging all runs.
+type externalSorter struct {
+	ctx           context.Context
+	job           *Job
+	binID         uint
+	maxBufferSize int64
+
+	buffer        []keyValue
+	bufferedBytes int64
+
+	fs       corfs.FileSystem // filesystem that runs are spilled to
+	spillDir string           // directory that runs are spilled to
+	tempDir  bool             // whether spillDir is a local temporary directory that should be removed
+	runs     []string         // paths of spilled runs
+	runCount int              // total number of runs written
+	readers  []io.ReadCloser  // open readers of runs being merged
+}
+
+func newExternalSorter(ctx context.Context, job *Job, binID uint) *externalSorter {
+	return &externalSorter{
+		ctx:           ctx,
+		job:           job,
+		binID:         binID,
+		maxBufferSize: job.config.SortBufferSize,
+	}
+}
+
+// add adds a record to the sorter, spilling buffered records if necessary
+func (s *externalSorter) add(kv keyValue) error {
+	s.buffer = append(s.buffer, kv)
+	s.bufferedBytes += int64(len(kv.Key) + len(kv.Value))
+
+	if s.maxBufferSize > 0 && s.bufferedBytes >= s.maxBufferSize {
+		return s.spill()
+	}
+	return nil
+}
+
+// initSpillDir determines where the sorter's runs are written
+func (s *externalSorter) initSpillDir() error {
+	if location := s.job.config.SpillLocation; location != "" {
+		s.fs = corfs.InferFilesystem(location)
+		s.spillDir = s.fs.Join(location, fmt.Sprintf("spill-%d-%d", s.binID, time.Now().UnixNano()))
+		return nil
+	}
+
+	dir, err := ioutil.TempDir("", fmt.Sprintf("corral-spill-%d-", s.binID))
+	if err != nil {
+		return err
+	}
+	s.fs = &corfs.LocalFileSystem{}
+	s.spillDir = dir
+	s.tempDir = true
+	return nil
+}
+
+// spill sorts the buffered records and writes them to a new run
+func (s *externalSorter) spill() error {
+	if s.spillDir == "" {
+		if err := s.initSpillDir(); err != nil {
+			return err
+		}
+	}
+
+	s.job.sortRecords(s.buffer)
+	err := s.writeRun(&sliceIterator{records: s.buffer})
+
+	s.buffer = nil
+	s.bufferedBytes = 0
+	return err
+}
+
+// writeRun writes the (sorted) records of iter to a new run
+func (s *externalSorter) writeRun(iter recordIterator) error {
+	path := s.fs.Join(s.spillDir, fmt.Sprintf("run-%d", s.runCount))
+	s.runCount++
+	log.Debugf("Spilling sorted run to %s", path)
+
+	file, err := s.fs.OpenWriter(s.ctx, path)
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, path)
+
+	// Runs are private to the sorter, so they always use the compact binary format
+	writer, err := newIntermediateWriter(file, BinaryFormat, s.job.config.IntermediateCompression)
+	if err != nil {
+		return err
+	}
+	for {
+		kv, err := iter.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			writer.close()
+			return err
+		}
+		if err := writer.write(kv); err != nil {
+			writer.close()
+			return err
+		}
+	}
+	return writer.close()
+}
+
+// openRuns opens iterators over the given runs
+func (s *externalSorter) openRuns(runs []string) ([]recordIterator, error) {
+	iters := make([]recordIterator, 0, len(runs))
+	for _, run := range runs {
+		reader, err := s.fs.OpenReader(s.ctx, run, 0)
+		if err != nil {
+			return nil, err
+		}
+		s.readers = append(s.readers, reader)
+		iter, err := newIntermediateReader(reader, BinaryFormat, s.job.config.IntermediateCompression)
+		if err != nil {
+			return nil, err
+		}
+		iters = append(iters, iter)
+	}
+	return iters, nil
+}
+
+// closeReaders closes the readers of all opened runs
+func (s *externalSorter) closeReaders() {
+	for _, reader := range s.readers {
+		reader.Close()
+	}
+	s.readers = nil
+}
+
+// sorted returns an iterator over all added records, in sorted order.
+// No records may be added after sorted is called.
+func (s *externalSorter) sorted() (recordIterator, error) {
+	s.job.sortRecords(s.buffer)
+	if len(s.runs) == 0 {
+		return &sliceIterator{records: s.buffer}, nil
+	}
+
+	// Reduce the number of runs until they can be merged in a single pass
+	for len(s.runs)+1 > maxMergeFanIn {
+		iters, err := s.openRuns(s.runs[:maxMergeFanIn])
+		if err != nil {
+			return nil, err
+		}
+		merged, err := newMergeIterator(s.job.compareRecords, iters...)
+		if err != nil {
+			return nil, err
+		}
+
+		mergedRuns := s.runs[:maxMergeFanIn]
+		s.runs = s.runs[maxMergeFanIn:]
+		if err := s.writeRun(merged); err != nil {
+			return nil, err
+		}
+		s.closeReaders()
+		s.deleteRuns(mergedRuns)
+	}
+
+	iters, err := s.openRuns(s.runs)
+	if err != nil {
+		return nil, err
+	}
+	iters = append(iters, &sliceIterator{records: s.buffer})
+	return newMergeIterator(s.job.compareRecords, iters...)
+}
+
+func (s *externalSorter) deleteRuns(runs []string) {
+	for _, run := range runs {
+		if err := s.fs.Delete(run); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// close releases all resources held by the sorter, and deletes any spilled runs
+func (s *externalSorter) close() {
+	s.closeReaders()
+	s.deleteRuns(s.runs)
+	s.runs = nil
+	s.buffer = nil
+
+	if s.tempDir {
+		os.RemoveAll(s.spillDir)
+	}
+}
+
+// reduceGroups calls Reduce once per group of equivalent keys in the sorted records of iter.
+// Each call receives the first key of its group, and values are streamed to Reduce as
+// they are read from iter.
+func (j *Job) reduceGroups(ctx context.Context, iter recordIterator, emitter Emitter) error {
+	group := j.groupingComparator()
+	reduce := reduceFunc(j.Reduce)
+
+	kv, err := iter.next()
+	for err == nil && ctx.Err() == nil {
+		key := kv.Key
+		if partial, ok := emitter.(*partialEmitter); ok {
+			partial.startGroup(key)
+		}
+		valueChan := make(chan string)
+		done := make(chan struct{})
+		var reduceErr error
+		go func() {
+			defer close(done)
+			reduceErr = reduce(key, newValueIterator(valueChan), emitter)
+		}()
+
+		for err == nil && ctx.Err() == nil && group(key, kv.Key) == 0 {
+			select {
+			case valueChan <- kv.Value:
+			case <-done:
+				// Reduce returned without consuming all values; skip the rest of the group
+			case <-ctx.Done():
+			}
+			kv, err = iter.next()
+		}
+		close(valueChan)
+		<-done
+		if reduceErr != nil {
+			return fmt.Errorf("key %q: %w", truncate(key, 64), reduceErr)
+		}
+	}
+
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,166 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortRecords(t *testing.T) {
+	records := []keyValue{
+		{"b", "2"}, {"a", "3"}, {"c", "1"}, {"a", "1"}, {"b", "1"},
+	}
+
+	job := &Job{}
+	job.sortRecords(records)
+	assert.Equal(t, []keyValue{
+		{"a", "3"}, {"a", "1"}, {"b", "2"}, {"b", "1"}, {"c", "1"},
+	}, records)
+
+	// Reverse key order, with values sorted ascending
+	job = &Job{
+		SortComparator: func(a, b string) int {
+			return strings.Compare(b, a)
+		},
+		ValueComparator: strings.Compare,
+	}
+	job.sortRecords(records)
+	assert.Equal(t, []keyValue{
+		{"c", "1"}, {"b", "1"}, {"b", "2"}, {"a", "1"}, {"a", "3"},
+	}, records)
+}
+
+type collectingReducer struct {
+	keys   []string
+	values [][]string
+}
+
+func (c *collectingReducer) Reduce(key string, values ValueIterator, emitter Emitter) {
+	c.keys = append(c.keys, key)
+	collected := make([]string, 0)
+	for value := range values.Iter() {
+		collected = append(collected, value)
+	}
+	c.values = append(c.values, collected)
+}
+
+func TestReduceGroupsSecondarySort(t *testing.T) {
+	reducer := &collectingReducer{}
+
+	// Keys are "<user>#<timestamp>"; group by user so that Reduce sees each
+	// user's events in timestamp order
+	job := &Job{
+		Reduce: reducer,
+		GroupingComparator: func(a, b string) int {
+			return strings.Compare(strings.Split(a, "#")[0], strings.Split(b, "#")[0])
+		},
+	}
+	records := []keyValue{
+		{"bob#2", "bob-second"},
+		{"alice#3", "alice-third"},
+		{"bob#1", "bob-first"},
+		{"alice#1", "alice-first"},
+		{"alice#2", "alice-second"},
+	}
+
+	job.sortRecords(records)
+	err := job.reduceGroups(context.Background(), &sliceIterator{records: records}, nil)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"alice#1", "bob#1"}, reducer.keys)
+	assert.Equal(t, [][]string{
+		{"alice-first", "alice-second", "alice-third"},
+		{"bob-first", "bob-second"},
+	}, reducer.values)
+}
+
+type firstValueReducer struct{}
+
+func (firstValueReducer) Reduce(key string, values ValueIterator, emitter Emitter) {
+	<-values.Iter()
+}
+
+func TestReduceGroupsPartiallyConsumed(t *testing.T) {
+	job := &Job{Reduce: firstValueReducer{}}
+
+	// Must not block when Reduce doesn't consume all values
+	records := []keyValue{{"a", "1"}, {"a", "2"}, {"a", "3"}, {"b", "1"}}
+	err := job.reduceGroups(context.Background(), &sliceIterator{records: records}, nil)
+	assert.Nil(t, err)
+}
+
+func TestMergeIterator(t *testing.T) {
+	job := &Job{}
+	iter, err := newMergeIterator(job.compareRecords,
+		&sliceIterator{records: []keyValue{{"a", "1"}, {"c", "1"}}},
+		&sliceIterator{records: []keyValue{}},
+		&sliceIterator{records: []keyValue{{"a", "2"}, {"b", "1"}, {"d", "1"}}},
+	)
+	assert.Nil(t, err)
+
+	merged := make([]keyValue, 0)
+	for {
+		kv, err := iter.next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		merged = append(merged, kv)
+	}
+
+	// Equal records keep the order of their sources
+	assert.Equal(t, []keyValue{{"a", "1"}, {"a", "2"}, {"b", "1"}, {"c", "1"}, {"d", "1"}}, merged)
+}
+
+func testSortWithSpills(t *testing.T, numRecords int, cfg *config) {
+	job := &Job{config: cfg}
+	sorter := newExternalSorter(context.Background(), job, 0)
+
+	for i := 0; i < numRecords; i++ {
+		err := sorter.add(keyValue{Key: fmt.Sprintf("%04d", (i*37)%numRecords), Value: strconv.Itoa(i)})
+		assert.Nil(t, err)
+	}
+	assert.NotEmpty(t, sorter.runs)
+	spillDir := sorter.spillDir
+
+	iter, err := sorter.sorted()
+	assert.Nil(t, err)
+
+	for i := 0; i < numRecords; i++ {
+		kv, err := iter.next()
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("%04d", i), kv.Key)
+	}
+	_, err = iter.next()
+	assert.Equal(t, io.EOF, err)
+
+	sorter.close()
+	files, _ := filepath.Glob(filepath.Join(spillDir, "*"))
+	assert.Empty(t, files)
+}
+
+func TestExternalSorterSpills(t *testing.T) {
+	// Every 10 records are spilled to a separate run
+	testSortWithSpills(t, 100, &config{SortBufferSize: 50})
+}
+
+func TestExternalSorterMultiPassMerge(t *testing.T) {
+	// Every record is spilled to a separate run, requiring multiple merge passes
+	testSortWithSpills(t, 3*maxMergeFanIn, &config{SortBufferSize: 1})
+}
+
+func TestExternalSorterSpillLocation(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	testSortWithSpills(t, 100, &config{SortBufferSize: 50, SpillLocation: tmpdir})
+}
@@ -0,0 +1,305 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	humanize "github.com/dustin/go-humanize"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// trackedKeys is the number of keys whose output size each mapper tracks
+	trackedKeys = 64
+	// reportedKeys is the number of largest keys that are reported by mappers, and in skew reports
+	reportedKeys = 10
+	// skewThreshold is the ratio of the largest reduce bin's size to the median bin size
+	// above which a job's shuffle is reported as skewed
+	skewThreshold = 4.0
+)
+
+// keyStat records the (approximate) size of the map output of a key
+type keyStat struct {
+	Key   string
+	Bytes int64
+}
+
+// topKeys tracks the largest keys of a mapper's output with the Space-Saving algorithm.
+// Tracked sizes may overestimate, but never underestimate, the size of a key's output.
+type topKeys struct {
+	sizes map[string]int64
+}
+
+// add records size bytes of output for key
+func (t *topKeys) add(key string, size int64) {
+	if t.sizes == nil {
+		t.sizes = make(map[string]int64, trackedKeys)
+	}
+	if _, tracked := t.sizes[key]; tracked || len(t.sizes) < trackedKeys {
+		t.sizes[key] += size
+		return
+	}
+
+	// Replace the smallest tracked key, as the new key may have been evicted earlier
+	minKey, minSize := "", int64(math.MaxInt64)
+	for k, s := range t.sizes {
+		if s < minSize || (s == minSize && k < minKey) {
+			minKey, minSize = k, s
+		}
+	}
+	delete(t.sizes, minKey)
+	t.sizes[key] = minSize + size
+}
+
+// top returns the n largest tracked keys, largest first
+func (t *topKeys) top(n int) []keyStat {
+	return largestKeys(t.sizes, n)
+}
+
+// largestKeys returns the n largest keys of sizes, largest first
+func largestKeys(sizes map[string]int64, n int) []keyStat {
+	stats := make([]keyStat, 0, len(sizes))
+	for key, size := range sizes {
+		stats = append(stats, keyStat{Key: key, Bytes: size})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// mapperStats describes the output of a map task: the number of bytes written to each
+// intermediate bin, and the mapper's largest keys
+type mapperStats struct {
+	BinBytes map[uint]int64
+	TopKeys  []keyStat
+}
+
+// shuffleStats collects the mapperStats of a job's map tasks. Only the output of the last
+// successful attempt of each map task is recorded.
+type shuffleStats struct {
+	mut     sync.Mutex
+	mappers map[uint]mapperStats
+}
+
+// record records the stats of a successful map task
+func (s *shuffleStats) record(mapperID uint, stats mapperStats) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.mappers == nil {
+		s.mappers = make(map[uint]mapperStats)
+	}
+	s.mappers[mapperID] = stats
+}
+
+// get returns the recorded stats of a map task
+func (s *shuffleStats) get(mapperID uint) (mapperStats, bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	stats, ok := s.mappers[mapperID]
+	return stats, ok
+}
+
+// reset discards all recorded stats
+func (s *shuffleStats) reset() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.mappers = nil
+}
+
+// skewReport summarizes the distribution of a job's map output between reduce bins
+type skewReport struct {
+	BinBytes []int64
+	TopKeys  []keyStat
+}
+
+// report aggregates the recorded stats into a skewReport of numBins reduce bins
+func (s *shuffleStats) report(numBins uint) skewReport {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	binBytes := make([]int64, numBins)
+	keySizes := make(map[string]int64)
+	for _, stats := range s.mappers {
+		for bin, size := range stats.BinBytes {
+			if bin < numBins {
+				binBytes[bin] += size
+			}
+		}
+		for _, key := range stats.TopKeys {
+			keySizes[key.Key] += key.Bytes
+		}
+	}
+	return skewReport{BinBytes: binBytes, TopKeys: largestKeys(keySizes, reportedKeys)}
+}
+
+// largestBin returns the ID and size of the largest reduce bin, and the median bin size
+func (r skewReport) largestBin() (uint, int64, int64) {
+	var largest uint
+	for bin, size := range r.BinBytes {
+		if size > r.BinBytes[largest] {
+			largest = uint(bin)
+		}
+	}
+	sizes := append([]int64{}, r.BinBytes...)
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	return largest, r.BinBytes[largest], sizes[len(sizes)/2]
+}
+
+// skewed returns true if the largest reduce bin is much larger than the median bin
+func (r skewReport) skewed() bool {
+	if len(r.BinBytes) < 2 {
+		return false
+	}
+	_, largest, median := r.largestBin()
+	return float64(largest) > skewThreshold*math.Max(float64(median), 1)
+}
+
+// log logs the report for the named job, warning if its shuffle is skewed
+func (r skewReport) log(name string) {
+	if len(r.BinBytes) == 0 {
+		return
+	}
+	bin, largest, median := r.largestBin()
+	log.Infof("Job %s - Largest Reduce Bin: %d (%s, median %s)", name, bin, humanize.Bytes(uint64(largest)), humanize.Bytes(uint64(median)))
+	if !r.skewed() {
+		return
+	}
+
+	keys := make([]string, len(r.TopKeys))
+	for i, key := range r.TopKeys {
+		keys[i] = fmt.Sprintf("%q (%s)", truncate(key.Key, 64), humanize.Bytes(uint64(key.Bytes)))
+	}
+	log.Warnf("Job %s - Map output is skewed: reduce bin %d is %.1fx the median size. Largest keys: %s",
+		name, bin, float64(largest)/math.Max(float64(median), 1), strings.Join(keys, ", "))
+}
+
+// hotKeyEmitter discards emitted records, recording the total size of each key's records
+type hotKeyEmitter struct {
+	counterSet
+	sizes map[string]int64
+	size  int64
+}
+
+func (h *hotKeyEmitter) Emit(key, value string) error {
+	if h.sizes == nil {
+		h.sizes = make(map[string]int64)
+	}
+	h.sizes[key] += int64(len(key) + len(value))
+	h.size += int64(len(key) + len(value))
+	return nil
+}
+
+func (h *hotKeyEmitter) close() error {
+	return nil
+}
+
+func (h *hotKeyEmitter) bytesWritten() int64 {
+	return h.size
+}
+
+// sampleHotKeys returns the keys that hold more than a reducer's share of the job's map output
+// (i.e. 1/numBins of it), as estimated by sampling the Mapper's output. Hot keys are sorted.
+func (j *Job) sampleHotKeys(ctx context.Context, splits []InputSplit, numBins uint) ([]string, error) {
+	emitter := &hotKeyEmitter{}
+	if _, err := j.sampleMapOutput(ctx, splits, emitter); err != nil {
+		return nil, err
+	}
+
+	hotKeys := make([]string, 0)
+	for key, size := range emitter.sizes {
+		if size*int64(numBins) > emitter.size {
+			hotKeys = append(hotKeys, key)
+		}
+	}
+	sort.Strings(hotKeys)
+	return hotKeys, nil
+}
+
+// saltedKey returns the key that the salt-th share of a hot key's values is partitioned by
+func saltedKey(key string, salt int) string {
+	return fmt.Sprintf("%s\x00%d", key, salt)
+}
+
+// partialOutputName returns the name of the file that a reducer writes its partial results
+// for hot keys to. Partial results are intermediate input to the hot key merge task, which is
+// run as an extra reduce task with the ID numBins.
+func partialOutputName(numBins, binID uint) string {
+	return fmt.Sprintf("map-bin%d-r%d.out", numBins, binID)
+}
+
+// partialEmitter writes the output of Reduce to an Emitter, except for the output of hot keys,
+// which is written to an intermediate file for the hot key merge task
+type partialEmitter struct {
+	Emitter
+	ctx     context.Context
+	job     *Job
+	path    string
+	hotKeys map[string]bool
+	hot     bool
+
+	mut    sync.Mutex
+	writer *intermediateWriter
+}
+
+func newPartialEmitter(ctx context.Context, job *Job, output Emitter, path string) *partialEmitter {
+	hotKeys := make(map[string]bool, len(job.hotKeys))
+	for _, key := range job.hotKeys {
+		hotKeys[key] = true
+	}
+	return &partialEmitter{
+		Emitter: output,
+		ctx:     ctx,
+		job:     job,
+		path:    path,
+		hotKeys: hotKeys,
+	}
+}
+
+// startGroup is called before each call to Reduce with the key of the group being reduced
+func (p *partialEmitter) startGroup(key string) {
+	p.hot = p.hotKeys[key]
+}
+
+func (p *partialEmitter) Emit(key, value string) error {
+	if !p.hot {
+		return p.Emitter.Emit(key, value)
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.writer == nil {
+		file, err := p.job.fileSystem.OpenWriter(p.ctx, p.path)
+		if err != nil {
+			return err
+		}
+		p.writer, err = newIntermediateWriter(file, p.job.config.IntermediateFormat, p.job.config.IntermediateCompression)
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return p.writer.write(keyValue{Key: key, Value: value})
+}
+
+// closePartial closes the file of partial results, if any were written
+func (p *partialEmitter) closePartial() error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.writer == nil {
+		return nil
+	}
+	err := p.writer.close()
+	p.writer = nil
+	return err
+}
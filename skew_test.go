@@ -0,0 +1,150 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+func TestTopKeys(t *testing.T) {
+	var keys topKeys
+	for i := 0; i < trackedKeys; i++ {
+		keys.add(fmt.Sprintf("key%d", i), 1)
+	}
+	keys.add("hot", 100)
+	keys.add("key1", 5)
+
+	assert.Len(t, keys.sizes, trackedKeys)
+	// The evicted key's size is attributed to the new key, so sizes are never underestimated
+	assert.Equal(t, []keyStat{{"hot", 101}, {"key1", 6}}, keys.top(2))
+}
+
+func TestShuffleStatsReport(t *testing.T) {
+	var stats shuffleStats
+	stats.record(0, mapperStats{
+		BinBytes: map[uint]int64{0: 10, 1: 100},
+		TopKeys:  []keyStat{{"hot", 90}, {"cold", 10}},
+	})
+	stats.record(1, mapperStats{
+		BinBytes: map[uint]int64{1: 100, 2: 10},
+		TopKeys:  []keyStat{{"hot", 90}, {"warm", 10}},
+	})
+	// Later attempts of a map task replace the stats of earlier attempts
+	stats.record(1, mapperStats{
+		BinBytes: map[uint]int64{1: 100, 2: 20},
+		TopKeys:  []keyStat{{"hot", 90}, {"warm", 20}},
+	})
+
+	report := stats.report(3)
+	assert.Equal(t, []int64{10, 200, 20}, report.BinBytes)
+	assert.Equal(t, []keyStat{{"hot", 180}, {"warm", 20}, {"cold", 10}}, report.TopKeys)
+	assert.True(t, report.skewed())
+
+	bin, largest, median := report.largestBin()
+	assert.Equal(t, uint(1), bin)
+	assert.Equal(t, int64(200), largest)
+	assert.Equal(t, int64(20), median)
+
+	stats.reset()
+	assert.False(t, stats.report(3).skewed())
+}
+
+func TestSampleHotKeys(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte(strings.Repeat("hot ", 50)+"a b c d e f g h"), 0600)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.fileSystem = &corfs.LocalFileSystem{}
+	job.config = &config{}
+	splits := job.inputSplits(context.Background(), []string{inputPath}, 1000)
+
+	hotKeys, err := job.sampleHotKeys(context.Background(), splits, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"hot"}, hotKeys)
+}
+
+func TestMapperEmitterSaltsHotKeys(t *testing.T) {
+	mFs := &mockFs{writers: make(map[string]*testWriteCloser)}
+	emitter := newMapperEmitter(context.Background(), 8, 0, "out", mFs)
+	emitter.hotKeys = map[string]bool{"hot": true}
+	emitter.salts = 4
+
+	for i := 0; i < 8; i++ {
+		assert.Nil(t, emitter.Emit("hot", "1"))
+	}
+	assert.Nil(t, emitter.close())
+
+	// The hot key's values are spread across several bins
+	assert.True(t, len(mFs.writers) > 1)
+	stats := emitter.stats()
+	assert.Equal(t, []keyStat{{"hot", 32}}, stats.TopKeys)
+	assert.Len(t, stats.BinBytes, len(mFs.writers))
+}
+
+// testSumJob counts words by summing their counts, so its Reduce can be applied to its own output
+type testSumJob struct {
+	testWCJob
+}
+
+func (testSumJob) Reduce(key string, values ValueIterator, emitter Emitter) {
+	sum := 0
+	for value := range values.Iter() {
+		count, _ := strconv.Atoi(value)
+		sum += count
+	}
+	emitter.Emit(key, strconv.Itoa(sum))
+}
+
+func TestRunSaltHotKeys(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte(strings.Repeat("hot ", 100)+"a b c d e f g h"), 0600)
+
+	job := NewJob(testSumJob{}, testSumJob{})
+	job.SaltHotKeys = 4
+	driver := NewDriver(job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithNumReducers(4),
+	)
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	parts, err := filepath.Glob(filepath.Join(tmpdir, "output-part-*"))
+	assert.Nil(t, err)
+	// The merged results of hot keys are written to an extra output part
+	assert.Len(t, parts, 5)
+
+	counts := make(map[string][]string)
+	for _, part := range parts {
+		output, err := ioutil.ReadFile(part)
+		assert.Nil(t, err)
+		for _, kv := range testOutputToKeyValues(string(output)) {
+			counts[kv.Key] = append(counts[kv.Key], kv.Value)
+		}
+	}
+	assert.Equal(t, []string{"100"}, counts["hot"])
+	assert.Equal(t, []string{"1"}, counts["a"])
+	assert.Len(t, counts, 9)
+
+	// Partial results are removed once they've been merged
+	partials, err := filepath.Glob(filepath.Join(tmpdir, "map-bin*"))
+	assert.Nil(t, err)
+	assert.Empty(t, partials)
+}
@@ -0,0 +1,214 @@
+package corral
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SpeculationPolicy controls the speculative execution of straggling tasks. Once enough
+// of a phase's tasks have finished, duplicate attempts are launched for tasks that have
+// been running much longer than is typical, and the output of whichever attempt of a task
+// finishes first is committed. The other attempts are cancelled.
+type SpeculationPolicy struct {
+	// Quantile is the fraction (0-1] of a phase's tasks that must finish before any
+	// duplicate attempts are launched.
+	Quantile float64
+	// Multiplier determines which tasks are stragglers: tasks that have been running
+	// for longer than Multiplier times the median duration of finished tasks.
+	Multiplier float64
+}
+
+// speculationInterval is how often running tasks are checked for stragglers
+var speculationInterval = time.Second
+
+// phaseTask tracks the attempts of a single task of a phase. Attempts of a task may
+// run concurrently; the first to succeed is committed, and the others are cancelled.
+type phaseTask struct {
+	ctx        context.Context
+	phase      Phase
+	binID      uint
+	mut        sync.Mutex
+	started    time.Time
+	finished   time.Time
+	attempts   int
+	committed  bool
+	committing chan struct{} // closed once an in-progress commit finishes
+	speculated bool
+	cancels    map[int]context.CancelFunc
+}
+
+func newPhaseTask(ctx context.Context, phase Phase, binID uint) *phaseTask {
+	return &phaseTask{
+		ctx:     ctx,
+		phase:   phase,
+		binID:   binID,
+		cancels: make(map[int]context.CancelFunc),
+	}
+}
+
+// startAttempt allocates a new attempt of the task, and returns its number and the context
+// to run it with. It returns false if the task has already been committed.
+func (t *phaseTask) startAttempt() (int, context.Context, bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.committed {
+		return 0, nil, false
+	}
+	if t.started.IsZero() {
+		t.started = time.Now()
+	}
+
+	attempt := t.attempts
+	t.attempts++
+	ctx, cancel := context.WithCancel(t.ctx)
+	t.cancels[attempt] = cancel
+	return attempt, ctx, true
+}
+
+// finishAttempt commits the output of an attempt that succeeded (if no other attempt has
+// been committed), or aborts it. It returns true if the task has been committed, and
+// otherwise returns the error that the attempt failed with. Attempts that succeed once the
+// task's context is done (e.g. because another task of the phase failed) aren't committed.
+// Output is committed and aborted without holding the task's lock, as this may be slow
+// (e.g. on S3, where each file is copied). A successful attempt that finishes while another
+// attempt is committing waits for that commit, and is committed instead if it fails.
+func (t *phaseTask) finishAttempt(job *Job, attempt int, err error) (bool, error) {
+	t.mut.Lock()
+	t.cancels[attempt]()
+	delete(t.cancels, attempt)
+	if err == nil {
+		err = t.ctx.Err()
+	}
+	for err == nil && t.committing != nil {
+		committing := t.committing
+		t.mut.Unlock()
+		<-committing
+		t.mut.Lock()
+	}
+
+	if t.committed {
+		t.mut.Unlock()
+		job.abortTask(t.phase, t.binID, attempt)
+		return true, nil
+	}
+	if err != nil {
+		t.mut.Unlock()
+		job.abortTask(t.phase, t.binID, attempt)
+		return false, err
+	}
+
+	committing := make(chan struct{})
+	t.committing = committing
+	t.mut.Unlock()
+	err = job.commitTask(t.phase, t.binID, attempt)
+	t.mut.Lock()
+	t.committing = nil
+	close(committing)
+
+	if err != nil {
+		t.mut.Unlock()
+		job.abortTask(t.phase, t.binID, attempt)
+		return false, err
+	}
+
+	t.committed = true
+	t.finished = time.Now()
+	for _, cancel := range t.cancels {
+		cancel()
+	}
+	t.mut.Unlock()
+	return true, nil
+}
+
+// numAttempts returns the number of attempts of the task that have been started
+func (t *phaseTask) numAttempts() int {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.attempts
+}
+
+// isCommitted returns true if an attempt of the task has been committed
+func (t *phaseTask) isCommitted() bool {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.committed
+}
+
+// stragglers returns the running tasks that should be speculatively executed
+func (s SpeculationPolicy) stragglers(tasks []*phaseTask, now time.Time) []*phaseTask {
+	durations := make([]time.Duration, 0, len(tasks))
+	running := make([]*phaseTask, 0)
+	for _, task := range tasks {
+		task.mut.Lock()
+		if task.committed {
+			durations = append(durations, task.finished.Sub(task.started))
+		} else if !task.started.IsZero() && !task.speculated {
+			running = append(running, task)
+		}
+		task.mut.Unlock()
+	}
+
+	minFinished := int(math.Ceil(s.Quantile * float64(len(tasks))))
+	if len(durations) == 0 || len(durations) < minFinished {
+		return nil
+	}
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+	threshold := time.Duration(s.Multiplier * float64(durations[len(durations)/2]))
+
+	stragglers := make([]*phaseTask, 0)
+	for _, task := range running {
+		task.mut.Lock()
+		if now.Sub(task.started) > threshold {
+			stragglers = append(stragglers, task)
+		}
+		task.mut.Unlock()
+	}
+	return stragglers
+}
+
+// speculate periodically launches duplicate attempts of straggling tasks until ctx is done.
+// Duplicate attempts only run while there is spare concurrency, and aren't retried if they fail.
+func (d *Driver) speculate(ctx context.Context, job *Job, tasks []*phaseTask, slots taskSlots, wg *sync.WaitGroup, run taskFunc) {
+	ticker := time.NewTicker(speculationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, task := range job.config.Speculation.stragglers(tasks, now) {
+				if !slots.tryAcquire() {
+					break
+				}
+				attempt, attemptCtx, ok := task.startAttempt()
+				if !ok {
+					slots.release()
+					continue
+				}
+				task.mut.Lock()
+				task.speculated = true
+				task.mut.Unlock()
+
+				log.Infof("Launching speculative attempt %d of %s task %d", attempt, task.phase, task.binID)
+				wg.Add(1)
+				go func(task *phaseTask) {
+					defer wg.Done()
+					defer slots.release()
+					err := run(attemptCtx, task.binID, attempt)
+					if _, err := task.finishAttempt(job, attempt, err); err != nil {
+						log.Debugf("Speculative attempt %d of %s task %d failed: %s", attempt, task.phase, task.binID, err)
+					}
+				}(task)
+			}
+		}
+	}
+}
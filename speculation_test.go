@@ -0,0 +1,255 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+func testFinishedTask(binID uint, duration time.Duration, now time.Time) *phaseTask {
+	task := newPhaseTask(context.Background(), MapPhase, binID)
+	task.started = now.Add(-duration)
+	task.finished = now
+	task.committed = true
+	return task
+}
+
+func testRunningTask(binID uint, elapsed time.Duration, now time.Time) *phaseTask {
+	task := newPhaseTask(context.Background(), MapPhase, binID)
+	task.started = now.Add(-elapsed)
+	return task
+}
+
+func TestSpeculationPolicyStragglers(t *testing.T) {
+	now := time.Now()
+	policy := SpeculationPolicy{Quantile: 0.5, Multiplier: 2}
+
+	straggler := testRunningTask(3, 5*time.Second, now)
+	tasks := []*phaseTask{
+		testFinishedTask(0, time.Second, now),
+		testFinishedTask(1, 2*time.Second, now),
+		testRunningTask(2, 3*time.Second, now),
+		straggler,
+		newPhaseTask(context.Background(), MapPhase, 4), // Not started
+	}
+
+	// 2 of 5 tasks haven't finished
+	assert.Empty(t, policy.stragglers(tasks, now))
+
+	tasks = append(tasks, testFinishedTask(5, 2*time.Second, now))
+	assert.Equal(t, []*phaseTask{straggler}, policy.stragglers(tasks, now))
+
+	// Tasks are only speculated once
+	straggler.speculated = true
+	assert.Empty(t, policy.stragglers(tasks, now))
+}
+
+func TestPhaseTaskCommitsFirstAttempt(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	task := newPhaseTask(context.Background(), ReducePhase, 0)
+	first, firstCtx, ok := task.startAttempt()
+	assert.True(t, ok)
+	second, _, ok := task.startAttempt()
+	assert.True(t, ok)
+	assert.Equal(t, 0, first)
+	assert.Equal(t, 1, second)
+
+	for _, attempt := range []int{first, second} {
+		attemptDir := job.attemptPath(ReducePhase, 0, attempt)
+		assert.Nil(t, os.MkdirAll(attemptDir, 0700))
+		ioutil.WriteFile(filepath.Join(attemptDir, "output-part-0"), []byte(fmt.Sprint(attempt)), 0600)
+	}
+
+	committed, err := task.finishAttempt(job, second, nil)
+	assert.True(t, committed)
+	assert.Nil(t, err)
+
+	// The other attempt is cancelled, and its output is discarded
+	assert.NotNil(t, firstCtx.Err())
+	committed, err = task.finishAttempt(job, first, nil)
+	assert.True(t, committed)
+	assert.Nil(t, err)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1", string(output))
+	_, err = os.Stat(job.attemptPath(ReducePhase, 0, first))
+	assert.True(t, os.IsNotExist(err))
+
+	_, _, ok = task.startAttempt()
+	assert.False(t, ok)
+	assert.Equal(t, 2, task.numAttempts())
+}
+
+func TestPhaseTaskFailedAttempt(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	task := newPhaseTask(context.Background(), MapPhase, 0)
+	attempt, _, _ := task.startAttempt()
+	committed, err := task.finishAttempt(job, attempt, errors.New("exploded"))
+	assert.False(t, committed)
+	assert.EqualError(t, err, "exploded")
+}
+
+func TestPhaseTaskCancelled(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	task := newPhaseTask(ctx, ReducePhase, 0)
+	attempt, attemptCtx, _ := task.startAttempt()
+	attemptDir := job.attemptPath(ReducePhase, 0, attempt)
+	assert.Nil(t, os.MkdirAll(attemptDir, 0700))
+	ioutil.WriteFile(filepath.Join(attemptDir, "output-part-0"), []byte("output"), 0600)
+
+	// Attempts that succeed after the phase is cancelled aren't committed
+	cancel()
+	assert.NotNil(t, attemptCtx.Err())
+	committed, err := task.finishAttempt(job, attempt, nil)
+	assert.False(t, committed)
+	assert.Equal(t, context.Canceled, err)
+
+	_, err = os.Stat(filepath.Join(tmpdir, "output-part-0"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// blockingRenameFs is a local filesystem whose renames block until unblocked
+type blockingRenameFs struct {
+	corfs.LocalFileSystem
+	renaming chan struct{}
+	unblock  chan struct{}
+}
+
+func (b *blockingRenameFs) Rename(source, destination string) error {
+	b.renaming <- struct{}{}
+	<-b.unblock
+	return b.LocalFileSystem.Rename(source, destination)
+}
+
+func TestPhaseTaskCommitsWithoutLock(t *testing.T) {
+	job, tmpdir := testCommitJob(t)
+	defer os.RemoveAll(tmpdir)
+	fs := &blockingRenameFs{renaming: make(chan struct{}), unblock: make(chan struct{})}
+	job.fileSystem = fs
+
+	task := newPhaseTask(context.Background(), ReducePhase, 0)
+	first, _, _ := task.startAttempt()
+	second, _, _ := task.startAttempt()
+	for _, attempt := range []int{first, second} {
+		attemptDir := job.attemptPath(ReducePhase, 0, attempt)
+		assert.Nil(t, os.MkdirAll(attemptDir, 0700))
+		ioutil.WriteFile(filepath.Join(attemptDir, "output-part-0"), []byte(fmt.Sprint(attempt)), 0600)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		committed, err := task.finishAttempt(job, first, nil)
+		assert.True(t, committed)
+		assert.Nil(t, err)
+	}()
+	<-fs.renaming
+
+	// The task isn't locked while its output is being committed
+	assert.Equal(t, 2, task.numAttempts())
+	assert.False(t, task.isCommitted())
+
+	// Attempts that finish during the commit wait for it, and are then discarded
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		committed, err := task.finishAttempt(job, second, nil)
+		assert.True(t, committed)
+		assert.Nil(t, err)
+	}()
+
+	close(fs.unblock)
+	<-done
+	<-secondDone
+	assert.True(t, task.isCommitted())
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.Equal(t, fmt.Sprint(first), string(output))
+}
+
+// stragglingExecutor runs the first attempt of map task 0 until it is cancelled
+type stragglingExecutor struct {
+	localExecutor
+	mut       sync.Mutex
+	cancelled bool
+}
+
+func (s *stragglingExecutor) RunMapper(ctx context.Context, job *Job, jobNumber int, binID uint, attempt int, inputSplits []InputSplit) error {
+	if err := s.localExecutor.RunMapper(ctx, job, jobNumber, binID, attempt, inputSplits); err != nil {
+		return err
+	}
+	if binID == 0 && attempt == 0 {
+		<-ctx.Done()
+		s.mut.Lock()
+		s.cancelled = true
+		s.mut.Unlock()
+		return ctx.Err()
+	}
+	return nil
+}
+
+func TestRunSpeculativeExecution(t *testing.T) {
+	defer func(interval time.Duration) {
+		speculationInterval = interval
+	}(speculationInterval)
+	speculationInterval = 10 * time.Millisecond
+
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	for i := 0; i < 4; i++ {
+		inputPath := filepath.Join(tmpdir, fmt.Sprintf("test_input%d", i))
+		ioutil.WriteFile(inputPath, []byte("foo bar baz"), 0700)
+	}
+
+	driver := NewDriver(NewJob(testWCJob{}, testWCJob{}),
+		WithInputs(tmpdir),
+		WithWorkingLocation(tmpdir),
+		WithSplitSize(11),
+		WithMapBinSize(11),
+		WithSpeculativeExecution(SpeculationPolicy{Quantile: 0.5, Multiplier: 1}),
+	)
+	executor := &stragglingExecutor{}
+	driver.executor = executor
+
+	done := make(chan error)
+	go func() {
+		done <- driver.Run(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Straggling task was not speculatively executed")
+	}
+	assert.True(t, executor.cancelled)
+
+	output, err := ioutil.ReadFile(filepath.Join(tmpdir, "output-part-0"))
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []keyValue{
+		{"foo", "4"},
+		{"bar", "4"},
+		{"baz", "4"},
+	}, testOutputToKeyValues(string(output)))
+}
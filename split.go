@@ -8,18 +8,18 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-// inputSplit contains the information about a contiguous chunk of an input file.
-// startOffset and endOffset are inclusive. For example, if the startOffset was 10
-// and the endOffset was 14, then the inputSplit would describe a 5 byte chunk
+// InputSplit contains the information about a contiguous chunk of an input file.
+// StartOffset and EndOffset are inclusive. For example, if the StartOffset was 10
+// and the EndOffset was 14, then the InputSplit would describe a 5 byte chunk
 // of the file.
-type inputSplit struct {
+type InputSplit struct {
 	Filename    string // The file that the input split operates on
 	StartOffset int64  // The starting byte index of the split in the file
 	EndOffset   int64  // The ending byte index (inclusive) of the split in the file
 }
 
-// Size returns the number of bytes that the inputSplit spans
-func (i inputSplit) Size() int64 {
+// Size returns the number of bytes that the InputSplit spans
+func (i InputSplit) Size() int64 {
 	return i.EndOffset - i.StartOffset + 1
 }
 
@@ -30,12 +30,18 @@ func min(a, b int64) int64 {
 	return b
 }
 
-func splitInputFile(file corfs.FileInfo, maxSplitSize int64) []inputSplit {
-	splits := make([]inputSplit, 0)
+// splitInputFile divides file into inputSplits of at most maxSplitSize bytes.
+// Compressed files are not splittable, so they are described by a single InputSplit.
+func splitInputFile(file corfs.FileInfo, maxSplitSize int64) []InputSplit {
+	if isCompressedInput(file.Name) {
+		return wholeFileSplit(file)
+	}
+
+	splits := make([]InputSplit, 0)
 
 	for startOffset := int64(0); startOffset < file.Size; startOffset += maxSplitSize {
 		endOffset := min(startOffset+maxSplitSize-1, file.Size-1)
-		newSplit := inputSplit{
+		newSplit := InputSplit{
 			Filename:    file.Name,
 			StartOffset: startOffset,
 			EndOffset:   endOffset,
@@ -46,23 +52,35 @@ func splitInputFile(file corfs.FileInfo, maxSplitSize int64) []inputSplit {
 	return splits
 }
 
+// wholeFileSplit returns a single InputSplit that spans the entirety of file
+func wholeFileSplit(file corfs.FileInfo) []InputSplit {
+	if file.Size == 0 {
+		return []InputSplit{}
+	}
+	return []InputSplit{{
+		Filename:    file.Name,
+		StartOffset: 0,
+		EndOffset:   file.Size - 1,
+	}}
+}
+
 // inputBin is a collection of inputSplits.
 type inputBin struct {
-	splits []inputSplit
+	splits []InputSplit
 	// The total size of the inputBin. (The sum of the size of all splits)
 	size int64
 }
 
 // packInputSplits partitions inputSplits into bins.
 // The combined size of each bin will be no greater than maxBinSize
-func packInputSplits(splits []inputSplit, maxBinSize int64) [][]inputSplit {
+func packInputSplits(splits []InputSplit, maxBinSize int64) [][]InputSplit {
 	if len(splits) == 0 {
-		return [][]inputSplit{}
+		return [][]InputSplit{}
 	}
 
 	bins := make([]*inputBin, 1)
 	bins[0] = &inputBin{
-		splits: make([]inputSplit, 0),
+		splits: make([]InputSplit, 0),
 		size:   0,
 	}
 
@@ -75,14 +93,14 @@ func packInputSplits(splits []inputSplit, maxBinSize int64) [][]inputSplit {
 			currBin.size += split.Size()
 		} else {
 			newBin := &inputBin{
-				splits: []inputSplit{split},
+				splits: []InputSplit{split},
 				size:   split.Size(),
 			}
 			bins = append(bins, newBin)
 		}
 	}
 
-	binnedSplits := make([][]inputSplit, len(bins))
+	binnedSplits := make([][]InputSplit, len(bins))
 	totalSize := int64(0)
 	for i, bin := range bins {
 		totalSize += bin.size
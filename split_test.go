@@ -21,9 +21,9 @@ func TestPackInputSplits(t *testing.T) {
 	}
 
 	for _, test := range packingTests {
-		splits := make([]inputSplit, len(test.splitSizes))
+		splits := make([]InputSplit, len(test.splitSizes))
 		for i, size := range test.splitSizes {
-			splits[i] = inputSplit{
+			splits[i] = InputSplit{
 				StartOffset: 0,
 				EndOffset:   int64(size) - 1,
 			}
@@ -55,6 +55,7 @@ func TestCalculateInputSplits(t *testing.T) {
 		{3, 3, []int64{0}, []int64{2}},
 		{10, 3, []int64{0, 3, 6, 9}, []int64{2, 5, 8, 9}},
 		{5, 10, []int64{0}, []int64{4}},
+		{0, 3, []int64{}, []int64{}},
 	}
 
 	for _, test := range calculateSplitTests {
@@ -72,6 +73,16 @@ func TestCalculateInputSplits(t *testing.T) {
 	}
 }
 
+func TestSplitCompressedInputFile(t *testing.T) {
+	fInfo := corfs.FileInfo{
+		Name: "input.gz",
+		Size: 10,
+	}
+
+	splits := splitInputFile(fInfo, 3)
+	assert.Equal(t, []InputSplit{{Filename: "input.gz", StartOffset: 0, EndOffset: 9}}, splits)
+}
+
 func TestSplitSize(t *testing.T) {
 	var splitSizeTests = []struct {
 		startOffset  int64
@@ -84,7 +95,7 @@ func TestSplitSize(t *testing.T) {
 	}
 
 	for _, test := range splitSizeTests {
-		split := inputSplit{
+		split := InputSplit{
 			StartOffset: test.startOffset,
 			EndOffset:   test.endOffset,
 		}
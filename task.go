@@ -1,6 +1,8 @@
 package corral
 
 import (
+	"fmt"
+
 	"github.com/bcongdon/corral/internal/pkg/corfs"
 )
 
@@ -13,21 +15,41 @@ const (
 	ReducePhase
 )
 
+func (p Phase) String() string {
+	switch p {
+	case MapPhase:
+		return "map"
+	case ReducePhase:
+		return "reduce"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
 // task defines a serialized description of a single unit of work
 // in a MapReduce job, as well as the necessary information for a
 // remote executor to initialize itself and begin working.
 type task struct {
-	JobNumber        int
-	Phase            Phase
-	BinID            uint
-	IntermediateBins uint
-	Splits           []inputSplit
-	FileSystemType   corfs.FileSystemType
-	WorkingLocation  string
-	Cleanup          bool
+	JobNumber               int
+	Phase                   Phase
+	BinID                   uint
+	Attempt                 int
+	IntermediateBins        uint
+	SplitPoints             []string `json:",omitempty"`
+	HotKeys                 []string `json:",omitempty"`
+	Splits                  []InputSplit
+	FileSystemType          corfs.FileSystemType
+	WorkingLocation         string
+	CombineBufferSize       int64
+	SortBufferSize          int64
+	SpillLocation           string
+	IntermediateFormat      IntermediateFormat
+	IntermediateCompression Compression
+	Params                  map[string]string `json:",omitempty"`
 }
 
 type taskResult struct {
 	BytesRead    int
 	BytesWritten int
+	Counters     map[string]int64 `json:",omitempty"`
+	Shuffle      *mapperStats     `json:",omitempty"`
 }
@@ -0,0 +1,91 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+)
+
+// TaskContext describes the task attempt that runs a Mapper or Reducer
+type TaskContext struct {
+	// JobNumber is the index of the task's job in its driver
+	JobNumber int
+	Phase     Phase
+	BinID     uint
+	Attempt   int
+	// NumReducers is the number of reduce tasks (i.e. intermediate bins) of the job
+	NumReducers uint
+	// Splits are the input splits read by a map task. Reduce tasks have no splits.
+	Splits []InputSplit
+	// Sampling is true if the Mapper is being run by the driver to sample its output
+	// (e.g. to choose the split points of a TotalOrder job), rather than by a map task
+	Sampling bool
+
+	ctx    context.Context
+	params map[string]string
+}
+
+// Context returns the context of the task attempt, which is cancelled if the attempt is cancelled
+func (t TaskContext) Context() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// Param returns the value of the named parameter of the job (set with WithParam, or by the
+// params setting), or "" if the parameter isn't set
+func (t TaskContext) Param(key string) string {
+	return t.params[key]
+}
+
+// SetupHook is an optional interface for Mappers and Reducers that initialize state before
+// processing records (e.g. by loading a lookup table, or opening a database connection).
+// Setup is called once per task attempt, before the first call to Map or Reduce.
+// If Setup returns an error, the task attempt fails. Tasks run in the same process (e.g. by
+// the local executor) share the job's Mapper and Reducer, so hooks must be safe to call concurrently.
+type SetupHook interface {
+	Setup(ctx TaskContext) error
+}
+
+// TeardownHook is an optional interface for Mappers and Reducers that release state once
+// they have processed all of a task's records. Teardown is called once per task attempt whose
+// Setup (if any) succeeded, after the last call to Map or Reduce, even if the attempt failed.
+// If Teardown returns an error, the task attempt fails.
+type TeardownHook interface {
+	Teardown(ctx TaskContext) error
+}
+
+// taskContext returns the TaskContext of an attempt of one of the job's tasks
+func (j *Job) taskContext(ctx context.Context, phase Phase, binID uint, attempt int, splits []InputSplit) TaskContext {
+	return TaskContext{
+		JobNumber:   j.number,
+		Phase:       phase,
+		BinID:       binID,
+		Attempt:     attempt,
+		NumReducers: j.intermediateBins,
+		Splits:      splits,
+		ctx:         ctx,
+		params:      j.config.Params,
+	}
+}
+
+// setupTask calls Setup on hook, if it implements SetupHook
+func setupTask(hook interface{}, ctx TaskContext) error {
+	if setup, ok := unwrapAdapter(hook).(SetupHook); ok {
+		if err := setup.Setup(ctx); err != nil {
+			return fmt.Errorf("%s task setup failed: %w", ctx.Phase, err)
+		}
+	}
+	return nil
+}
+
+// teardownTask calls Teardown on hook, if it implements TeardownHook. It returns err (the
+// result of the task), or the error returned by Teardown if the task otherwise succeeded.
+func teardownTask(hook interface{}, ctx TaskContext, err error) error {
+	if teardown, ok := unwrapAdapter(hook).(TeardownHook); ok {
+		if teardownErr := teardown.Teardown(ctx); teardownErr != nil && err == nil {
+			return fmt.Errorf("%s task teardown failed: %w", ctx.Phase, teardownErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,136 @@
+package corral
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+// testHookJob is a word count job that records the calls to its Setup and Teardown hooks
+type testHookJob struct {
+	testWCJob
+	mut       *sync.Mutex
+	setups    *[]TaskContext
+	teardowns *[]TaskContext
+	setupErr  error
+}
+
+func newTestHookJob() testHookJob {
+	return testHookJob{
+		mut:       &sync.Mutex{},
+		setups:    &[]TaskContext{},
+		teardowns: &[]TaskContext{},
+	}
+}
+
+func (h testHookJob) Setup(ctx TaskContext) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	*h.setups = append(*h.setups, ctx)
+	return h.setupErr
+}
+
+func (h testHookJob) Teardown(ctx TaskContext) error {
+	h.mut.Lock()
+	defer h.mut.Unlock()
+	*h.teardowns = append(*h.teardowns, ctx)
+	return nil
+}
+
+func TestRunWithTaskHooks(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("the quick brown fox jumps over the lazy dog"), 0600)
+
+	job0 := NewJob(testWCJob{}, testWCJob{})
+	hooks := newTestHookJob()
+	job1 := NewJob(hooks, hooks, WithParam("table", "lookup.csv"))
+	driver := NewMultiStageDriver([]*Job{job0, job1},
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithNumReducers(2),
+	)
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	// Each map and reduce task is set up and torn down once
+	assert.Len(t, *hooks.setups, 3)
+	assert.ElementsMatch(t, *hooks.setups, *hooks.teardowns)
+
+	var reduceBins []uint
+	for _, ctx := range *hooks.setups {
+		assert.Equal(t, 1, ctx.JobNumber)
+		assert.Equal(t, uint(2), ctx.NumReducers)
+		assert.Equal(t, "lookup.csv", ctx.Param("table"))
+		assert.Equal(t, "", ctx.Param("missing"))
+		assert.False(t, ctx.Sampling)
+
+		if ctx.Phase == MapPhase {
+			assert.Len(t, ctx.Splits, 2)
+		} else {
+			assert.Empty(t, ctx.Splits)
+			reduceBins = append(reduceBins, ctx.BinID)
+		}
+	}
+	assert.ElementsMatch(t, []uint{0, 1}, reduceBins)
+
+	// Parameters set on one job don't apply to the driver's other jobs
+	assert.Empty(t, job0.config.Params)
+}
+
+func TestTaskTeardownAfterFailure(t *testing.T) {
+	hooks := newTestHookJob()
+	job := NewJob(hooks, hooks)
+	job.fileSystem = corfs.InitFilesystem(corfs.Local)
+	job.outputPath = "."
+	job.intermediateBins = 1
+
+	err := job.runMapper(context.Background(), 0, 0, []InputSplit{{Filename: "missing_file"}})
+	assert.NotNil(t, err)
+	assert.Len(t, *hooks.setups, 1)
+	assert.Len(t, *hooks.teardowns, 1)
+}
+
+func TestTaskSetupFailure(t *testing.T) {
+	hooks := newTestHookJob()
+	hooks.setupErr = errors.New("setup error")
+	job := NewJob(hooks, hooks)
+	job.fileSystem = corfs.InitFilesystem(corfs.Local)
+	job.outputPath = "."
+
+	err := job.runReducer(context.Background(), 0, 0)
+	assert.True(t, errors.Is(err, hooks.setupErr))
+	assert.Len(t, *hooks.setups, 1)
+	assert.Empty(t, *hooks.teardowns)
+}
+
+func TestSamplingTaskHooks(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("foo bar baz"), 0600)
+
+	hooks := newTestHookJob()
+	job := NewJob(hooks, hooks)
+	job.fileSystem = corfs.InitFilesystem(corfs.Local)
+	splits := job.inputSplits(context.Background(), []string{inputPath}, 100)
+
+	_, err = job.sampleMapOutput(context.Background(), splits, &sizingEmitter{})
+	assert.Nil(t, err)
+	assert.Len(t, *hooks.setups, 1)
+	assert.Len(t, *hooks.teardowns, 1)
+	assert.True(t, (*hooks.setups)[0].Sampling)
+}
@@ -0,0 +1,72 @@
+package corral
+
+import (
+	"context"
+	"sort"
+)
+
+// keySamplingEmitter discards emitted records, recording only their keys
+type keySamplingEmitter struct {
+	counterSet
+	keys []string
+	size int64
+}
+
+func (k *keySamplingEmitter) Emit(key, value string) error {
+	k.keys = append(k.keys, key)
+	k.size += int64(len(key) + len(value))
+	return nil
+}
+
+func (k *keySamplingEmitter) close() error {
+	return nil
+}
+
+func (k *keySamplingEmitter) bytesWritten() int64 {
+	return k.size
+}
+
+// sampleSplitPoints computes the split points of a total order partitioning of the job's map
+// output into numBins bins. Keys are sampled by running the Mapper over a sample of the input
+// splits, and split points are chosen so that the sampled keys are divided evenly between bins.
+// Split points are ordered by the job's SortComparator. Fewer than numBins-1 split points are
+// returned if fewer keys were sampled.
+func (j *Job) sampleSplitPoints(ctx context.Context, splits []InputSplit, numBins uint) ([]string, error) {
+	emitter := &keySamplingEmitter{}
+	if _, err := j.sampleMapOutput(ctx, splits, emitter); err != nil {
+		return nil, err
+	}
+
+	compare := j.sortComparator()
+	keys := emitter.keys
+	sort.Slice(keys, func(a, b int) bool {
+		return compare(keys[a], keys[b]) < 0
+	})
+
+	splitPoints := make([]string, 0, numBins)
+	for i := uint(1); i < numBins && len(keys) > 0; i++ {
+		point := keys[int(i)*len(keys)/int(numBins)]
+		// Duplicate split points would leave bins empty
+		if len(splitPoints) > 0 && compare(splitPoints[len(splitPoints)-1], point) == 0 {
+			continue
+		}
+		splitPoints = append(splitPoints, point)
+	}
+	return splitPoints, nil
+}
+
+// rangePartition returns a PartitionFunc that assigns keys to bins by the range of
+// splitPoints that they fall in. Bin i holds the keys that sort before splitPoints[i]
+// and at or after splitPoints[i-1], so every key of a bin sorts before every key of the
+// next bin.
+func rangePartition(splitPoints []string, compare Comparator) PartitionFunc {
+	return func(key string, numBins uint) uint {
+		bin := uint(sort.Search(len(splitPoints), func(i int) bool {
+			return compare(key, splitPoints[i]) < 0
+		}))
+		if bin >= numBins {
+			bin = numBins - 1
+		}
+		return bin
+	}
+}
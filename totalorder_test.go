@@ -0,0 +1,112 @@
+package corral
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bcongdon/corral/internal/pkg/corfs"
+)
+
+func TestRangePartition(t *testing.T) {
+	partition := rangePartition([]string{"c", "f"}, strings.Compare)
+
+	assert.Equal(t, uint(0), partition("a", 3))
+	assert.Equal(t, uint(1), partition("c", 3))
+	assert.Equal(t, uint(1), partition("d", 3))
+	assert.Equal(t, uint(2), partition("f", 3))
+	assert.Equal(t, uint(2), partition("z", 3))
+
+	// Keys are never assigned to a bin that doesn't exist
+	assert.Equal(t, uint(1), partition("z", 2))
+
+	assert.Equal(t, uint(0), rangePartition(nil, strings.Compare)("a", 3))
+}
+
+func TestSampleSplitPoints(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("h g f e d c b a"), 0600)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.fileSystem = &corfs.LocalFileSystem{}
+	job.config = &config{}
+	splits := job.inputSplits(context.Background(), []string{inputPath}, 100)
+
+	splitPoints, err := job.sampleSplitPoints(context.Background(), splits, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "e", "g"}, splitPoints)
+
+	// Split points follow the job's SortComparator
+	job.SortComparator = func(a, b string) int { return strings.Compare(b, a) }
+	splitPoints, err = job.sampleSplitPoints(context.Background(), splits, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"d"}, splitPoints)
+}
+
+func TestSampleSplitPointsWithFewKeys(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte("a a a b"), 0600)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.fileSystem = &corfs.LocalFileSystem{}
+	job.config = &config{}
+	splits := job.inputSplits(context.Background(), []string{inputPath}, 100)
+
+	splitPoints, err := job.sampleSplitPoints(context.Background(), splits, 8)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, splitPoints)
+}
+
+func TestRunTotalOrder(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	words := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		words = append(words, fmt.Sprintf("word%03d", (i*37)%200))
+	}
+	inputPath := filepath.Join(tmpdir, "input")
+	ioutil.WriteFile(inputPath, []byte(strings.Join(words, " ")), 0600)
+
+	job := NewJob(testWCJob{}, testWCJob{})
+	job.TotalOrder = true
+	driver := NewDriver(job,
+		WithInputs(inputPath),
+		WithWorkingLocation(tmpdir),
+		WithNumReducers(4),
+	)
+	err = driver.Run(context.Background())
+	assert.Nil(t, err)
+
+	keys := make([]string, 0, len(words))
+	for part := 0; part < 4; part++ {
+		output, err := ioutil.ReadFile(filepath.Join(tmpdir, fmt.Sprintf("output-part-%d", part)))
+		assert.Nil(t, err)
+
+		partKeys := make([]string, 0)
+		for _, kv := range testOutputToKeyValues(string(output)) {
+			partKeys = append(partKeys, kv.Key)
+		}
+		assert.NotEmpty(t, partKeys, "part %d", part)
+		keys = append(keys, partKeys...)
+	}
+
+	// Concatenating the output parts gives globally sorted output
+	assert.Len(t, keys, len(words))
+	assert.IsIncreasing(t, keys)
+}